@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"gopiler/internal"
@@ -10,13 +11,13 @@ import (
 func verifyFile(inputFile string) string {
 	// Ensure we got a file
 	if inputFile == "" {
-		fmt.Println("Error: No input file specified.")
+		fmt.Fprintln(os.Stderr, "Error: No input file specified.")
 		flag.Usage()
 		os.Exit(1)
 	}
 	filebytes, err := os.ReadFile(inputFile)
 	if err != nil {
-		fmt.Println("Error processing file:", err)
+		fmt.Fprintln(os.Stderr, "Error processing file:", err)
 		os.Exit(1)
 	}
 	var filedata string = string(filebytes)
@@ -25,22 +26,129 @@ func verifyFile(inputFile string) string {
 	return filedata
 }
 
+// writes out every program that compiled, one file each or one archive
+func writeImages(compiler *internal.Compiler, path string, format internal.ImageFormat, archive bool) {
+	var images []internal.Image = compiler.Images()
+	if len(images) == 0 {
+		compiler.Warn("No program compiled. Nothing was written.", "GOPILER")
+		return
+	}
+
+	if archive {
+		file, err := os.Create(path)
+		if err == nil {
+			err = internal.WriteArchive(file, images, format)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing archive:", err)
+			os.Exit(1)
+		}
+		compiler.Info(fmt.Sprintf("Wrote %d program(s) to %s", len(images), path), "GOPILER", true)
+		return
+	}
+
+	for _, image := range images {
+		var name string = path
+		if compiler.ProgramCount() > 1 {
+			name = internal.ImageFileName(path, image.Program, format)
+		}
+		if err := os.WriteFile(name, internal.EncodeImage(image, format), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing file:", err)
+			os.Exit(1)
+		}
+		compiler.Info(fmt.Sprintf("Wrote program %d to %s", image.Program+1, name), "GOPILER", true)
+	}
+}
+
 func main() {
 	inputFile := flag.String("f", "", "String; Path to source for compilation")
 	terseMode := flag.Bool("t", false, "Bool; Toggle Terse Mode (less detailed output)")
+	stopAfter := flag.String("s", "codegen", "String; Stop after stage: lexer, parser, semantic, or codegen")
+	runMode := flag.Bool("run", false, "Bool; Run each compiled program on the emulator and show its output")
+	stepLimit := flag.Int("steps", 10000, "Int; Max instructions a program may run before it is stopped")
+	optimize := flag.Bool("O", false, "Bool; Run the peephole optimizer over generated code and report the bytes saved")
+	outputPath := flag.String("o", "", "String; Write each compiled program's machine code to this path (name_1, name_2... for more than one)")
+	outputFormat := flag.String("format", "hex", "String; Format for -o: raw, hex, ihex (Intel HEX), go, or c")
+	jsonMode := flag.Bool("json", false, "Bool; Print every program's tokens, trees, symbols, assembly, memory, and diagnostics as JSON instead of the logs")
+	treeFormat := flag.String("tree", "text", "String; Also print each program's CST and AST at the end as: text, dot (Graphviz), or mermaid")
+	archive := flag.Bool("archive", false, "Bool; With -o, write every program into one zip archive instead of a file each")
 	flag.Parse()
 
+	stage, err := internal.ParseStage(*stopAfter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	graphFormat, err := internal.ParseGraphFormat(*treeFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	format, err := internal.ParseImageFormat(*outputFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	// stdout is the JSON document, and it already has both trees
+	if *jsonMode && graphFormat != internal.GraphText {
+		fmt.Fprintln(os.Stderr, "Error: -tree cannot be used with -json, which already includes the CST and AST")
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	var filedata string = verifyFile(*inputFile)
-	internal.SetVerbose(!*terseMode)
-	internal.SetWebMode(false)
+	var outputs map[int]string = make(map[int]string) // what each program that ran printed
+	var compiler *internal.Compiler = internal.NewCompiler()
+	compiler.SetVerbose(!*terseMode)
+	compiler.SetOptimize(*optimize)
+	compiler.SetWebMode(*jsonMode) // the logs would get mixed into the JSON
 
-	internal.Info(fmt.Sprintf("Starting compilation of: %s with verbose mode: %t", *inputFile, !*terseMode), "GOPILER", true)
+	compiler.Info(fmt.Sprintf("Starting compilation of: %s with verbose mode: %t", *inputFile, !*terseMode), "GOPILER", true)
 
 	if len(filedata) == 0 {
-		internal.Warn("Source file empty. No compilation will be executed.", "GOPILER")
+		compiler.Warn("Source file empty. No compilation will be executed.", "GOPILER")
 	} else {
-		internal.Lex(filedata)
+		compiler.Compile(filedata, stage)
+
+		if *runMode && stage == internal.StageCodeGen {
+			for program := 0; program < compiler.ProgramCount(); program++ {
+				if output, _ := compiler.Run(program, *stepLimit); compiler.GetMemoryImage(program) != nil {
+					outputs[program] = output
+				}
+			}
+		}
+		if *outputPath != "" && stage == internal.StageCodeGen {
+			writeImages(compiler, *outputPath, format, *archive)
+		}
 	}
 
-	internal.Info("All compilations complete.", "GOPILER", true)
+	compiler.Info("All compilations complete.", "GOPILER", true)
+
+	// the text trees are already in the logs
+	if graphFormat != internal.GraphText && len(filedata) > 0 {
+		fmt.Print(compiler.GetCstAs(graphFormat))
+		if stage != internal.StageLexer && stage != internal.StageParser {
+			fmt.Print(compiler.GetAstAs(graphFormat))
+		}
+	}
+
+	if *jsonMode {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		var dumps []internal.ProgramDump = compiler.Dump()
+		for program, output := range outputs {
+			output := output
+			dumps[program].Output = &output
+		}
+		if err := encoder.Encode(dumps); err != nil {
+			fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gopiler/internal"
+	"os"
+)
+
+func main() {
+	inputFile := flag.String("f", "", "String; Path to source for formatting")
+	checkMode := flag.Bool("check", false, "Bool; Exit with status 1 if the source is not formatted instead of printing it")
+	writeMode := flag.Bool("w", false, "Bool; Write the formatted source back to the file instead of printing it")
+	flag.Parse()
+
+	if *inputFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: No input file specified.")
+		flag.Usage()
+		os.Exit(2)
+	}
+	filebytes, err := os.ReadFile(*inputFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error processing file:", err)
+		os.Exit(2)
+	}
+
+	formatted, err := internal.Format(string(filebytes))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *inputFile, err)
+		os.Exit(2)
+	}
+
+	if *checkMode {
+		if formatted != string(filebytes) {
+			fmt.Fprintf(os.Stderr, "%s is not formatted\n", *inputFile)
+			os.Exit(1)
+		}
+	} else if *writeMode {
+		if err := os.WriteFile(*inputFile, []byte(formatted), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing file:", err)
+			os.Exit(2)
+		}
+	} else {
+		fmt.Print(formatted)
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"gopiler/lsp"
+	"log"
+	"os"
+)
+
+// editors start this and talk to it over stdin/stdout
+func main() {
+	log.SetOutput(os.Stderr) // stdout belongs to the protocol
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"gopiler/internal"
+	"os"
+	"strings"
+)
+
+const help string = `Enter statements to run them; they go inside one block that stays open, so variables are kept.
+Statements with an unclosed { or comment continue on the next line.
+Positions are the line of the entry; :symbols also says which entry.
+  :tokens   tokens of the last entry
+  :ast      AST of the session
+  :symbols  symbol table of the session
+  :asm      assembly of the session
+  :reset    forget every entry
+  :help     this
+  :quit     leave (or Ctrl-D)`
+
+func main() {
+	var session *internal.Session = internal.NewSession()
+	var scanner *bufio.Scanner = bufio.NewScanner(os.Stdin)
+	fmt.Println("Gopiler REPL; :help for commands")
+
+	var pending []string // lines of an entry with braces still open
+	for {
+		if len(pending) == 0 {
+			fmt.Print(">>> ")
+		} else {
+			fmt.Print("... ")
+		}
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		var line string = scanner.Text()
+
+		if len(pending) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !command(session, strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+		pending = append(pending, line)
+		var entry string = strings.Join(pending, "\n")
+		if internal.EntryIsOpen(entry) {
+			continue
+		}
+		pending = nil
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		printed, diags, accepted := session.Enter(entry)
+		for _, diag := range diags {
+			fmt.Printf("%s: %s\n", strings.ToLower(string(diag.Severity)), diag.String())
+			if diag.Code == internal.CodeGenMemoryExceeded {
+				fmt.Println("The session no longer fits in memory; :reset to start over.")
+			}
+		}
+		if accepted && printed != "" {
+			fmt.Println(printed)
+		}
+	}
+}
+
+// run a : command, false to quit
+func command(session *internal.Session, cmd string) bool {
+	switch cmd {
+	case ":tokens":
+		show(session.Tokens())
+	case ":ast":
+		show(session.AST())
+	case ":symbols":
+		show(session.Symbols())
+	case ":asm":
+		show(session.Assembly())
+	case ":reset":
+		session.Reset()
+		fmt.Println("Session reset.")
+	case ":help":
+		fmt.Println(help)
+	case ":quit", ":q":
+		return false
+	default:
+		fmt.Printf("Unknown command %s; :help for commands\n", cmd)
+	}
+	return true
+}
+
+// print text ending with a newline
+func show(text string) {
+	fmt.Print(text)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		fmt.Println()
+	}
+}
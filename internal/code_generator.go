@@ -6,81 +6,81 @@ import (
 	"strings"
 )
 
-var (
+// code generator state - lives on the Compiler so that compilations do not share it
+type codeGenState struct {
 	memList       []*[256]byte
 	curMem        *[256]byte // Array of 256 bytes, all init to 0x00
 	asmList       []*[]byte
-	curAsm        []byte // so we can update it from indices later
-	curBytePtr    int    = 0
-	placeholders  []*placeholder
+	curAsm        []byte
+	irList        [][]*irInstr
+	ir            []*irInstr // the program so far, lowered to curMem and curAsm at the end
+	slots         []*slot
+	labelCount    int
 	curScope      *SymbolTable
-	genErrors     int             = 0
-	genWarns      int             = 0
-	endStackPtr   int             = 0
-	topHeapPtr    int             = 255                 // its really 254 as subtracts before access
-	boolMemAddr   [2]byte         = [2]byte{0xFF, 0x00} // we will use the last memory segment for comparison results
-	storedStrings map[string]int  = make(map[string]int)
-	usedScopes    map[string]bool = make(map[string]bool) // map just bc high lookups
-	firstTime     bool            = true                  // don't move down scope for block 0
-)
-
-type placeholder struct {
-	locations    []int // where it appears in code
-	asmLocations []int
-	symbol       *SymbolEntry // so we know which var its for - scope dependent
-	realAddr     [2]byte      // actual location after backpatching
-}
-
-func newPlaceholder(node *Node) *placeholder {
-	var symbol = lookupSymbol(node.Token.trueContent)
-	return &placeholder{locations: []int{}, asmLocations: []int{}, symbol: symbol, realAddr: [2]byte{}}
+	genErrors     int
+	genWarns      int
+	endStackPtr   int
+	topHeapPtr    int // its really 254 as subtracts before access
+	storedStrings map[string]int
+	usedScopes    map[string]bool // map just bc high lookups
+	firstTime     bool            // don't move down scope for block 0
+	optimize      bool            // run the peephole optimizer before lowering
+	strCompare    *stringCompare  // nil until a program compares strings
 }
 
 // takes in an ID
-func addPlaceholderLocation(node *Node, loc int, asmLoc int) {
-	var symbol *SymbolEntry = lookupSymbol(node.Token.trueContent)
-	for _, p := range placeholders {
-		if p.symbol == symbol {
-			p.locations = append(p.locations, loc)
-			p.asmLocations = append(p.asmLocations, asmLoc)
-			return
+func (c *Compiler) slotFor(node *Node) *slot {
+	var symbol *SymbolEntry = c.lookupSymbol(node.Token)
+	for _, s := range c.slots {
+		if s.symbol == symbol {
+			return s
 		}
 	}
-	// placeholder was not found
+	// slot was not found
 	// this happens SPECIFICALLY when var is redecl in a scope,
 	// but is being assigned before that new decl. Scope table knows, we don't!
-	Warn(fmt.Sprintf("!!! The usage of symbol %s in scope %s at (%d:%d) is referencing the redeclaration in this scope even before the redeclaration statement !!!",
-		symbol.name, curScope.scopeID, node.Token.location.line, node.Token.location.startPos), "CODE GENERATOR")
-	genWarns++
-	var newPlaceholder *placeholder = newPlaceholder(node)
-	placeholders = append(placeholders, newPlaceholder)
-	addPlaceholderLocation(node, loc, asmLoc)
+	c.report(SeverityWarning, StageCodeGen, CodeGenEarlyRedeclUse, node.Token.location, tokenWidth(node.Token),
+		fmt.Sprintf("!!! The usage of symbol %s in scope %s is referencing the redeclaration in this scope even before the redeclaration statement !!!",
+			symbol.name, c.curScope.scopeID), "")
+	c.genWarns++
+	return c.newSlot(symbol, c.curScope.scopeID)
 }
 
 // will always exist (thanks semantic analysis)
-func lookupSymbol(name string) *SymbolEntry {
-	var searchTable *SymbolTable = curScope
+// if it somehow does not, that is reported and a stand-in keeps generation going to its normal failure
+func (c *Compiler) lookupSymbol(id *Token) *SymbolEntry {
+	var searchTable *SymbolTable = c.curScope
 	for {
-		if searchTable.EntryExists(name) {
-			return searchTable.entries[name]
+		if searchTable.EntryExists(id.trueContent) {
+			return searchTable.entries[id.trueContent]
 
 		} else if searchTable.parentTable != nil {
 			searchTable = searchTable.parentTable // look above
+		} else {
+			c.report(SeverityError, StageCodeGen, CodeGenUnknownSymbol, id.location, tokenWidth(id),
+				fmt.Sprintf("Symbol [ %s ] is not in scope [ %s ] or any scope above it", id.trueContent, c.curScope.scopeID),
+				"Semantic analysis should have caught this, so it is a compiler bug.")
+			c.genErrors++
+			var standIn *SymbolEntry = NewTableEntry(id.trueContent, "int", id.location)
+			c.newSlot(standIn, c.curScope.scopeID) // so it is not also taken for an early use of a redeclaration
+			return standIn
 		}
 	}
 }
 
-func initMem(pNum int) {
-	for len(memList) <= pNum {
+func (c *Compiler) initMem(pNum int) {
+	for len(c.memList) <= pNum {
 		// new memory
 		var newMem [256]byte
-		memList = append(memList, &newMem)
-		curMem = &newMem
-
-		// new assembly
-		curAsm = []byte{}
-		curAsm = append(curAsm, "6502 Assembly:\n\t"...)
+		c.memList = append(c.memList, &newMem)
+		c.asmList = append(c.asmList, &[]byte{})
+		c.irList = append(c.irList, nil)
 	}
+	c.curMem = c.memList[pNum]
+
+	// new assembly
+	c.curAsm = []byte{}
+	c.curAsm = append(c.curAsm, asmHeader...)
 }
 
 func strIntToByte(strInt string) byte {
@@ -88,220 +88,213 @@ func strIntToByte(strInt string) byte {
 	return byte(num)
 }
 
-func CodeGeneration(ast *TokenTree, symbolTableTree *SymbolTableTree, pNum int) {
+// Generate produces the 256 byte memory image for an analyzed program.
+// The image is nil if code generation failed.
+func (c *Compiler) Generate(ast *TokenTree, symbolTableTree *SymbolTableTree, pNum int) (image *[256]byte, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
 	defer func() {
 		if r := recover(); r != nil {
-			CriticalError("code generator", r)
+			c.CriticalError("code generator", r)
+			image = nil
 		}
+		diags = c.diagnosticsSince(firstDiag)
 	}()
 
-	Info(fmt.Sprintf("Generating Code for program %d", pNum+1), "CODE GENERATOR", true)
-
-	Debug("Generating Code from AST...", "CODE GENERATOR")
-	initMem(pNum)
-	curScope = symbolTableTree.rootTable
-	generateCode(ast.rootNode)
-	addBytes([]byte{0x00}) // break
-	addAsm("BRK")
-	backpatch()
-
-	if genErrors == 0 {
-		Pass(fmt.Sprintf("Successfully generated machine code and assembly for program %d with 0 errors and %d warning(s).",
-			pNum+1, genWarns), "CODE GENERATOR")
-		Info(fmt.Sprintf("Program %d Assembly:\n%s\n%s", pNum+1, strings.Repeat("-", 75),
-			string(curAsm)), "GOPILER", true)
-		Info(fmt.Sprintf("Program %d 6502 Machine Code:\n%s\n%s", pNum+1, strings.Repeat("-", 75),
-			GetMachineCode(pNum, true)), "GOPILER", true)
+	c.Info(fmt.Sprintf("Generating Code for program %d", pNum+1), "CODE GENERATOR", true)
+	c.curProgram = pNum
+
+	c.Debug("Generating Code from AST...", "CODE GENERATOR")
+	c.initMem(pNum)
+	c.curScope = symbolTableTree.rootTable
+	c.generateCode(ast.rootNode)
+	c.emit(0x00, noOperand()) // break
+	c.generateRuntime()
+	if c.genErrors == 0 {
+		c.lower()
+	}
+
+	if c.genErrors == 0 {
+		c.Pass(fmt.Sprintf("Successfully generated machine code and assembly for program %d with 0 errors and %d warning(s).",
+			pNum+1, c.genWarns), "CODE GENERATOR")
+		c.Debug(fmt.Sprintf("Program %d IR:\n%s", pNum+1, irListing(c.ir)), "CODE GENERATOR")
+		c.Info(fmt.Sprintf("Program %d Assembly:\n%s\n%s", pNum+1, strings.Repeat("-", 75),
+			string(c.curAsm)), "GOPILER", true)
+		c.Info(fmt.Sprintf("Program %d 6502 Machine Code:\n%s\n%s", pNum+1, strings.Repeat("-", 75),
+			c.GetMachineCode(pNum, true)), "GOPILER", true)
+		image = c.memList[pNum]
 	} else {
-		Fail(fmt.Sprintf("Code Generation for program %d failed with %d error(s) and %d warning(s).",
-			pNum+1, genErrors, genWarns), "CODE GENERATOR")
-		errorMap[pNum] = "code generation"
-		asmList[pNum] = &[]byte{}
-		Info(fmt.Sprintf("Compilation of program %d aborted due to code generation error(s).",
+		c.Fail(fmt.Sprintf("Code Generation for program %d failed with %d error(s) and %d warning(s).",
+			pNum+1, c.genErrors, c.genWarns), "CODE GENERATOR")
+		c.errorMap[pNum] = "code generation"
+		c.asmList[pNum] = &[]byte{}
+		c.Info(fmt.Sprintf("Compilation of program %d aborted due to code generation error(s).",
 			pNum+1), "GOPILER", false)
 	}
 	// reset for next program
-	genErrors = 0
-	genWarns = 0
-	endStackPtr = 0
-	topHeapPtr = 255
-	curBytePtr = 0
-	placeholders = []*placeholder{}
-	curScope = nil
-	storedStrings = make(map[string]int)
-	usedScopes = make(map[string]bool)
-	firstTime = true
+	c.genErrors = 0
+	c.genWarns = 0
+	c.endStackPtr = 0
+	c.topHeapPtr = 255
+	c.ir = nil
+	c.slots = nil
+	c.labelCount = 0
+	c.strCompare = nil
+	c.curScope = nil
+	c.storedStrings = make(map[string]int)
+	c.usedScopes = make(map[string]bool)
+	c.firstTime = true
+	return image, diags
 }
 
-func generateCode(node *Node) {
-	if genErrors != 0 {
+func (c *Compiler) generateCode(node *Node) {
+	if c.genErrors != 0 {
 		return
 	}
 	switch node.Type {
 	case "<Block>":
-		if firstTime {
-			firstTime = false
+		if c.firstTime {
+			c.firstTime = false
 		} else {
-			scopeDown()
+			c.scopeDown()
 		}
 
 		for _, child := range node.Children {
-			generateCode(child)
+			c.generateCode(child)
 		}
 
-		scopeUp()
+		c.scopeUp()
 
 	case "<VarDecl>":
-		generateVarDecl(node)
+		c.generateVarDecl(node)
 	case "<AssignmentStatement>":
-		generateAssign(node)
+		c.generateAssign(node)
 	case "<PrintStatement>":
-		generatePrint(node)
+		c.generatePrint(node)
 	case "<IfStatement>", "<WhileStatement>":
-		generateIfWhile(node)
+		c.generateIfWhile(node)
 	default:
 		// the children of the node are important even if the node itself is not
 		for _, child := range node.Children {
-			generateCode(child)
+			c.generateCode(child)
 		}
 	}
 }
 
-func scopeDown() {
+func (c *Compiler) scopeDown() {
 	// once we go 'down' into a scope and back up from it,
 	// we can never go back 'down' into it
-	for _, downCandidate := range curScope.subTables {
+	for _, downCandidate := range c.curScope.subTables {
 		// see if it has been used
-		if _, exists := usedScopes[downCandidate.scopeID]; !exists {
-			// if not used it is our new scope
+		if _, exists := c.usedScopes[downCandidate.scopeID]; !exists {
+			// the first one not used is our new scope (children are in source order)
 			// we do all this to determine which child to move down into
-			curScope = downCandidate
+			c.curScope = downCandidate
+			return
 		}
 	}
 }
 
-func scopeUp() {
+func (c *Compiler) scopeUp() {
+	c.usedScopes[c.curScope.scopeID] = true
 	// don't go up if we are the highest we can go
-	if curScope.scopeID != "0" {
-		curScope = curScope.parentTable
+	if c.curScope.scopeID != "0" {
+		c.curScope = c.curScope.parentTable
 	}
 }
 
-func addBytes(newMem []byte) {
-	if curBytePtr+len(newMem) >= topHeapPtr {
-		curBytePtr -= len(newMem) // just overwrite the end of existing so not out of bounds
-		if genErrors == 0 {
-			Error("Memory size exceeded (256 Bytes)", "CODE GENERATOR")
-			genErrors++
-		}
-	}
-	for _, newByte := range newMem {
-		curMem[curBytePtr] = newByte
-		curBytePtr++
-	}
-}
-
-func addAsm(newAsm string) {
-	curAsm = append(curAsm, []byte(newAsm+" \n\t")...)
-}
-
 // type, id
-func generateVarDecl(node *Node) {
-	// placeholder for var
-	var temp *placeholder = newPlaceholder(node.Children[1])
-	placeholders = append(placeholders, temp)
+func (c *Compiler) generateVarDecl(node *Node) {
+	// slot for var
+	var id *Node = node.Children[1]
+	var varSlot *slot = c.newSlot(c.lookupSymbol(id.Token), c.curScope.scopeID)
 
 	// we initialize bools and ints to 0
 	if node.Children[0].Token.content == "I_TYPE" || node.Children[0].Token.content == "B_TYPE" {
 		// load 0 to accum for init
-		addBytes([]byte{0xA9, 0x00})
-		addAsm("LDA #$00")
+		c.emit(0xA9, immediate(0x00))
 	} else {
 		// init strings to instant break
 		// load last string heap addr (always a padded brk statement)
-		addBytes([]byte{0xA9, 0xFE})
-		addAsm("LDA #FE")
+		c.emit(0xA9, immediate(0xFE))
 	}
-	// store init value to address (temp 00s for now)
-	temp.locations = append(temp.locations, curBytePtr+1)
-	addBytes([]byte{0x8D, 0x00, 0x00})
-	temp.asmLocations = append(temp.asmLocations, len(curAsm)+4)
-	addAsm("STA _TEMP")
+	// store init value to the var
+	c.emit(0x8D, slotOperand(varSlot))
 }
 
 // id, expr
-func generateAssign(node *Node) {
+func (c *Compiler) generateAssign(node *Node) {
 	// edge case for incrementing an ID by 1
-	if node.Children[1].Type == "<Addition>" && node.Children[1].Children[0].Token.trueContent == "1" &&
+	if node.Children[1].Type == "<Addition>" && node.Children[1].Children[0].Type == "Token" &&
+		node.Children[1].Children[0].Token.trueContent == "1" &&
 		node.Children[1].Children[1].Type == "Token" && node.Children[1].Children[1].Token.tType == Identifier {
 
-		addPlaceholderLocation(node.Children[1].Children[1], curBytePtr+1, len(curAsm)+4)
-		addBytes([]byte{0xEE, 0x00, 0x00}) // increment it!
-		addAsm("INC _TEMP")
+		c.emit(0xEE, slotOperand(c.slotFor(node.Children[1].Children[1]))) // increment it!
 	} else {
 		// load up whatever expr it was
-		generateExpr(node.Children[1])
+		c.generateExpr(node.Children[1])
 		// store it
-		addPlaceholderLocation(node.Children[0], curBytePtr+1, len(curAsm)+4)
-		addBytes([]byte{0x8D, 0x00, 0x00})
-		addAsm("STA _TEMP")
+		c.emit(0x8D, slotOperand(c.slotFor(node.Children[0])))
 	}
 }
 
-func generateExpr(node *Node) {
+func (c *Compiler) generateExpr(node *Node) {
 	switch node.Type {
 	case "Token":
 		if node.Token.tType == Digit {
-			var b byte = strIntToByte(node.Token.trueContent)
-			addBytes([]byte{0xA9, b})
-			addAsm(fmt.Sprintf("LDA #$%02X", b))
+			c.emit(0xA9, immediate(strIntToByte(node.Token.trueContent)))
 		} else if node.Token.tType == Identifier {
-			addPlaceholderLocation(node, curBytePtr+1, len(curAsm)+4)
-			addBytes([]byte{0xAD, 0x00, 0x00}) // load accum from mem
-			addAsm("LDA _TEMP")
+			c.emit(0xAD, slotOperand(c.slotFor(node))) // load accum from mem
 		} else if node.Token.content == "STRING" {
 			// string, heap
 			// we store the heap addr in a var
-			var strHeapLoc byte = addToHeap(node.Token.trueContent)
-			addBytes([]byte{0xA9, strHeapLoc})
-			addAsm(fmt.Sprintf("LDA $#%02X", strHeapLoc))
+			c.emit(0xA9, immediate(c.addToHeap(node.Token.trueContent)))
 		} else if node.Token.content == "KEYW_TRUE" || node.Token.content == "KEYW_FALSE" {
-			generateComparison(node)
+			c.generateComparison(node)
 		}
 
 	case "<Addition>":
-		generateAdd(node)
+		c.generateAdd(node)
 
-	case "<Equality>", "<Inequality>":
-		generateComparison(node)
+	case "<Subtraction>":
+		c.generateSub(node)
+
+	case "<Equality>", "<Inequality>", "<Conjunction>", "<Disjunction>", "<Negation>",
+		"<LessThan>", "<GreaterThan>", "<LessOrEqual>", "<GreaterOrEqual>":
+		c.generateComparison(node)
 	}
 }
 
-// digit, digit/add
-func generateAdd(node *Node) {
+// digit/add/sub, digit/expr
+func (c *Compiler) generateAdd(node *Node) {
 	var digAddParams []*Token
 	var idAddParams []*Node
-	var curAddParent *Node = node
-	var param1 *Node
-	var param2 *Node
-
-	// collect all things to add
-	for { // loop until no more nested add
-		param1 = curAddParent.Children[0]
-		param2 = curAddParent.Children[1]
-		// param1 is always a digit
-		digAddParams = append(digAddParams, param1.Token)
-
-		if param2.Type == "Token" { // we are done moving down
-			if param2.Token.tType == Digit {
-				digAddParams = append(digAddParams, param2.Token)
-			} else { // id
-				idAddParams = append(idAddParams, param2)
-			}
-			break
+	var subAddParams []*Node
+
+	// collect all things to add - nested adds on either side are part of the same sum
+	var addends []*Node = []*Node{node}
+	for len(addends) > 0 {
+		var param *Node = addends[0]
+		addends = addends[1:]
+
+		if param.Type == "<Addition>" {
+			// in front of what is left so digits keep their order
+			addends = append([]*Node{param.Children[0], param.Children[1]}, addends...)
+		} else if param.Type == "Token" && param.Token.tType == Digit {
+			digAddParams = append(digAddParams, param.Token)
+		} else if param.Type == "Token" { // id
+			idAddParams = append(idAddParams, param)
+		} else { // a subtraction, worked out on its own
+			subAddParams = append(subAddParams, param)
 		}
-		// nested add
-		curAddParent = param2
+	}
+
+	// subtractions need A, so they go first and wait in a temp
+	var subTemps []*slot
+	for _, subtraction := range subAddParams {
+		c.generateSub(subtraction)
+		var temp *slot = c.newSlot(nil, c.curScope.scopeID)
+		c.emit(0x8D, slotOperand(temp))
+		subTemps = append(subTemps, temp)
 	}
 
 	// collapse all static digits down
@@ -312,308 +305,332 @@ func generateAdd(node *Node) {
 		digVal, _ := strconv.Atoi(token.trueContent)
 		digitTotal += digVal
 	}
+	if digitTotal > 255 {
+		c.report(SeverityWarning, StageCodeGen, CodeGenIntWrap, digAddParams[0].location, tokenWidth(digAddParams[0]),
+			fmt.Sprintf("The literals in this addition add up to %d, which wraps to %d", digitTotal, byte(digitTotal)),
+			"Integers are 8 bits: 0 to 255.")
+		c.genWarns++
+	}
 
 	// load collapsed digits to accum for adding
-	addBytes([]byte{0xA9, byte(digitTotal)})
-	addAsm(fmt.Sprintf("LDA #$%02X", byte(digitTotal)))
+	c.emit(0xA9, immediate(byte(digitTotal)))
 	if len(idAddParams) != 0 { // if we don't have IDs no adding needed
 		for _, id := range idAddParams {
 			// add them up!
-			addPlaceholderLocation(id, curBytePtr+1, len(curAsm)+4)
-			addBytes([]byte{0x6D, 0x00, 0x00})
-			addAsm("ADC _TEMP")
+			c.emit(0x6D, slotOperand(c.slotFor(id)))
+		}
+	}
+	for _, temp := range subTemps {
+		c.emit(0x6D, slotOperand(temp))
+	}
+	// result is in accum when done
+}
+
+// digit/add/sub, Expr
+// SBC takes what to subtract from memory, so anything but an ID is worked out into a temp first
+func (c *Compiler) generateSub(node *Node) {
+	var left *Node = node.Children[0]
+	var right *Node = node.Children[1]
+
+	var subtrahend *slot
+	if right.Type == "Token" && right.Token.tType == Identifier {
+		subtrahend = c.slotFor(right)
+	} else {
+		if left.Type == "Token" && right.Type == "Token" && right.Token.tType == Digit {
+			// the folder does all the ones that stay at or above 0
+			var digit *Token = left.Token
+			var difference int = int(strIntToByte(digit.trueContent)) - int(strIntToByte(right.Token.trueContent))
+			c.report(SeverityWarning, StageCodeGen, CodeGenIntWrap, digit.location, tokenWidth(digit),
+				fmt.Sprintf("The literals in this subtraction come to %d, which wraps to %d", difference, byte(difference)),
+				"Integers are 8 bits: 0 to 255.")
+			c.genWarns++
 		}
+		c.generateComparison(right)
+		subtrahend = c.newSlot(nil, c.curScope.scopeID)
+		c.emit(0x8D, slotOperand(subtrahend))
 	}
+
+	c.generateExpr(left)      // a digit is just loaded
+	c.emit(0x38, noOperand()) // set carry so nothing is borrowed
+	c.emit(0xED, slotOperand(subtrahend))
 	// result is in accum when done
 }
 
-func generatePrint(node *Node) {
+func (c *Compiler) generatePrint(node *Node) {
 	var toPrint = node.Children[0]
 	switch toPrint.Type {
 	case "Token":
 		if toPrint.Token.tType == Digit {
-			var b byte = strIntToByte(toPrint.Token.trueContent)
-			addBytes([]byte{0xA0, b}) // load Y with const
-			addAsm(fmt.Sprintf("LDY #$%02X", b))
-			addBytes([]byte{0xA2, 0x01}) // load X with 1 for Y printing
-			addAsm("LDX #$01")
+			c.emit(0xA0, immediate(strIntToByte(toPrint.Token.trueContent))) // load Y with const
+			c.emit(0xA2, immediate(0x01))                                    // load X with 1 for Y printing
 
 		} else if toPrint.Token.tType == Identifier {
-			var sym *SymbolEntry = lookupSymbol(toPrint.Token.trueContent)
+			var sym *SymbolEntry = c.lookupSymbol(toPrint.Token)
 			if sym.dataType == "int" || sym.dataType == "boolean" {
-				addPlaceholderLocation(node.Children[0], curBytePtr+1, len(curAsm)+4)
-				addBytes([]byte{0xAC, 0x00, 0x00}) // load Y from mem
-				addAsm("LDY _TEMP")
-				addBytes([]byte{0xA2, 0x01}) // load X with 1 for Y printing
-				addAsm("LDX #$01")
+				c.emit(0xAC, slotOperand(c.slotFor(toPrint))) // load Y from mem
+				c.emit(0xA2, immediate(0x01))                 // load X with 1 for Y printing
 
 			} else { // string ID
-				addPlaceholderLocation(node.Children[0], curBytePtr+1, len(curAsm)+4)
-				addBytes([]byte{0xAC, 0x00, 0x00}) // load Y w heap addr
-				addAsm("LDY _TEMP")
-				addBytes([]byte{0xA2, 0x02}) // load X with 2 for addr Y printing
-				addAsm("LDX #$02")
+				c.emit(0xAC, slotOperand(c.slotFor(toPrint))) // load Y w heap addr
+				c.emit(0xA2, immediate(0x02))                 // load X with 2 for addr Y printing
 			}
 		} else if toPrint.Token.content == "STRING" {
-			var strHeapLoc byte = addToHeap(toPrint.Token.trueContent)
-			addBytes([]byte{0xA0, strHeapLoc}) // load Y with heap addr
-			addAsm(fmt.Sprintf("LDY #$%02X", strHeapLoc))
-			addBytes([]byte{0xA2, 0x02}) // load X with 2 for addr Y printing
-			addAsm("LDX #$02")
+			c.emit(0xA0, immediate(c.addToHeap(toPrint.Token.trueContent))) // load Y with heap addr
+			c.emit(0xA2, immediate(0x02))                                   // load X with 2 for addr Y printing
 
 		} else if toPrint.Token.content == "KEYW_TRUE" {
-			addBytes([]byte{0xA0, 0x01}) // load Y with true
-			addAsm("LDY #$01")
-			addBytes([]byte{0xA2, 0x01}) // load X with 1 for Y printing
-			addAsm("LDX #$01")
+			c.emit(0xA0, immediate(0x01)) // load Y with true
+			c.emit(0xA2, immediate(0x01)) // load X with 1 for Y printing
 
 		} else if toPrint.Token.content == "KEYW_FALSE" {
-			addBytes([]byte{0xA0, 0x00}) // load Y with false
-			addAsm("LDY #$00")
-			addBytes([]byte{0xA2, 0x01}) // load X with 1 for Y printing
-			addAsm("LDX #$01")
+			c.emit(0xA0, immediate(0x00)) // load Y with false
+			c.emit(0xA2, immediate(0x01)) // load X with 1 for Y printing
 		}
 
-	case "<Addition>", "<Equality>", "<Inequality>": // results are in accum
+	case "<Addition>", "<Subtraction>", "<Equality>", "<Inequality>", "<Conjunction>", "<Disjunction>", "<Negation>",
+		"<LessThan>", "<GreaterThan>", "<LessOrEqual>", "<GreaterOrEqual>": // results are in accum
 		if toPrint.Type == "<Addition>" {
-			generateAdd(node.Children[0])
+			c.generateAdd(node.Children[0])
 		} else {
-			generateComparison(node.Children[0])
+			c.generateComparison(node.Children[0])
 		}
 
 		// we need to store it, no symbol ref to it though
-		var headlessPlaceholder *placeholder = &placeholder{[]int{}, []int{}, nil, [2]byte{}}
-		placeholders = append(placeholders, headlessPlaceholder)
-
-		headlessPlaceholder.locations = append(headlessPlaceholder.locations, curBytePtr+1)
-		addBytes([]byte{0x8D, 0x00, 0x00}) // store add result
-		headlessPlaceholder.asmLocations = append(headlessPlaceholder.asmLocations, len(curAsm)+4)
-		addAsm("STA _TEMP")
-
-		headlessPlaceholder.locations = append(headlessPlaceholder.locations, curBytePtr+1)
-		addBytes([]byte{0xAC, 0x00, 0x00}) // load stored result to Y
-		headlessPlaceholder.asmLocations = append(headlessPlaceholder.asmLocations, len(curAsm)+4)
-		addAsm("LDY _TEMP")
-		addBytes([]byte{0xA2, 0x01}) // load X with 1 for Y printing
-		addAsm("LDX #$01")
+		var temp *slot = c.newSlot(nil, c.curScope.scopeID)
+		c.emit(0x8D, slotOperand(temp)) // store add result
+		c.emit(0xAC, slotOperand(temp)) // load stored result to Y
+		c.emit(0xA2, immediate(0x01))   // load X with 1 for Y printing
 	}
 
-	addBytes([]byte{0xFF}) // print sys call
-	addAsm("SYS")
-}
-
-func backpatch() {
-	endStackPtr = curBytePtr
-	for _, p := range placeholders {
-		p.realAddr = [2]byte{0x00, byte(endStackPtr)}
-		endStackPtr++
-		if endStackPtr >= topHeapPtr {
-			if genErrors == 0 {
-				Error("Memory size exceeded (256 Bytes)", "CODE GENERATOR")
-				genErrors++
-			}
-		} else {
-			for _, loc := range p.locations {
-				// little endian
-				curMem[loc] = p.realAddr[1]
-				curMem[loc+1] = p.realAddr[0]
-			}
-
-			for _, loc := range p.asmLocations {
-				copy(curAsm[loc:], fmt.Sprintf("$%02X%02X", p.realAddr[0], p.realAddr[1]))
-			}
-		}
-	}
-	copyAsm := make([]byte, len(curAsm))
-	copy(copyAsm, curAsm)
-	asmList = append(asmList, &copyAsm)
+	c.emit(0xFF, noOperand()) // print sys call
 }
 
-func generateIfWhile(node *Node) {
-	var whileReturn int = curBytePtr
+func (c *Compiler) generateIfWhile(node *Node) {
+	var whileReturn *label = c.newLabel()
+	var skip *label = c.newLabel()
+	c.placeLabel(whileReturn)
 
 	var condition *Node = node.Children[0]
 	var block *Node = node.Children[1]
-	generateComparison(condition)
-	addBytes([]byte{0x8D, boolMemAddr[0], boolMemAddr[1]}) // move result of boolexpr to bool addr
-	addAsm("STA $00FF")
-	addBytes([]byte{0xA2, 0x01}) // load X with 1 (true)
-	addAsm("LDX #$01")
-	addBytes([]byte{0xEC, boolMemAddr[0], boolMemAddr[1]}) // compare X and booladdr to set Z
-	addAsm("CPX $00FF")
-
-	// prep jump
-	var jumpPlacehold int = curBytePtr + 1
-	addBytes([]byte{0xD0, 0x00})
-	var asmJumpFill int = len(curAsm) + 5
-	addAsm("BNE $_J")
-	var beforeBytePos int = curBytePtr
-
-	generateCode(block)
+	// the folder leaves an empty block behind for conditions it worked out
+	var neverSkips bool = condition.Type == "Token" && condition.Token.content == "KEYW_TRUE"
+	var neverRuns bool = condition.Type == "Token" && condition.Token.content == "KEYW_FALSE" && len(block.Children) == 0
+	if !neverSkips && !neverRuns {
+		c.generateComparison(condition)
+		c.emit(0x8D, scratch())       // move result of boolexpr to bool addr
+		c.emit(0xA2, immediate(0x01)) // load X with 1 (true)
+		c.emit(0xEC, scratch())       // compare X and booladdr to set Z
+		c.emit(0xD0, labelOperand(skip))
+	}
+
+	c.generateCode(block)
 
 	// whiles need to go back up
 	if node.Type == "<WhileStatement>" {
 		// we need the Z to be 0 so we always branch back
-		zFlagZero()
-
-		var jumpDist byte = byte((curBytePtr + 2) - whileReturn) // (count the D0 and val coming)
-		var jumpVal byte = 0xFF - jumpDist + 1                   // 2's comp
-		addBytes([]byte{0xD0, jumpVal})
-		addAsm(fmt.Sprintf("BNE $%02X", jumpVal))
+		c.zFlagZero()
+		c.emit(0xD0, labelOperand(whileReturn))
+	} else if len(node.Children) == 3 { // else
+		var elseBlock *Node = node.Children[2]
+		if len(elseBlock.Children) == 0 || neverRuns {
+			// nothing to jump over
+			c.placeLabel(skip)
+			c.generateCode(elseBlock)
+			return
+		}
+		var end *label = c.newLabel()
+		c.zFlagZero()
+		c.emit(0xD0, labelOperand(end)) // the if block ran, jump over the else
+		c.placeLabel(skip)
+		c.generateCode(elseBlock)
+		c.placeLabel(end)
+		return
 	}
-
-	// calculate original jump to skip block and backfill
-	var afterBytePos int = curBytePtr
-	curMem[jumpPlacehold] = byte(afterBytePos - beforeBytePos)
-	copy(curAsm[asmJumpFill:], fmt.Sprintf("%02X", byte(afterBytePos-beforeBytePos)))
+	c.placeLabel(skip)
 }
 
 // sets the z flag to 0
-func zFlagZero() {
-	addBytes([]byte{0xA9, 0x01}) // load accum 1 (true)
-	addAsm("LDA #$01")
-	addBytes([]byte{0x8D, boolMemAddr[0], boolMemAddr[1]}) // store in reserved bool mem loc
-	addAsm("STA $00FF")
-	addBytes([]byte{0xA2, 0x00}) // load X with 0
-	addAsm("LDX #$00")
-	addBytes([]byte{0xEC, boolMemAddr[0], boolMemAddr[1]})
-	addAsm("CPX $00FF")
+func (c *Compiler) zFlagZero() {
+	c.emit(0xA9, immediate(0x01)) // load accum 1 (true)
+	c.emit(0x8D, scratch())       // store in reserved bool mem loc
+	c.emit(0xA2, immediate(0x00)) // load X with 0
+	c.emit(0xEC, scratch())
 }
 
-func generateComparison(node *Node) {
+func (c *Compiler) generateComparison(node *Node) {
 	if node.Type == "Token" {
 		if node.Token.content == "KEYW_TRUE" {
-			addBytes([]byte{0xA9, 0x01}) // load 1 to accum
-			addAsm("LDA #$01")
+			c.emit(0xA9, immediate(0x01)) // load 1 to accum
 
 		} else if node.Token.content == "KEYW_FALSE" {
-			addBytes([]byte{0xA9, 0x00}) // load 0 to accum
-			addAsm("LDA #$00")
+			c.emit(0xA9, immediate(0x00)) // load 0 to accum
 
 		} else if node.Token.tType == Digit {
-			var b byte = strIntToByte(node.Token.trueContent)
-			addBytes([]byte{0xA9, b})
-			addAsm(fmt.Sprintf("LDA #$%02X", b))
+			c.emit(0xA9, immediate(strIntToByte(node.Token.trueContent)))
 
 		} else if node.Token.content == "STRING" {
-			var strHeapLoc byte = addToHeap(node.Token.trueContent)
-			addBytes([]byte{0xA9, strHeapLoc})
-			addAsm(fmt.Sprintf("LDA $#%02X", strHeapLoc))
+			c.emit(0xA9, immediate(c.addToHeap(node.Token.trueContent)))
 
 		} else {
 			// user var
-			addPlaceholderLocation(node, curBytePtr+1, len(curAsm)+4)
-			addBytes([]byte{0xAD, 0x00, 0x00}) // load accum from mem
-			addAsm("LDA _TEMP")
+			c.emit(0xAD, slotOperand(c.slotFor(node))) // load accum from mem
 		}
 		return
 	}
 
 	if node.Type == "<Addition>" {
 		// result goes in accum
-		generateAdd(node)
-	} else if node.Type == "<Equality>" || node.Type == "<Inequality>" {
-		var compLeft *Node = node.Children[0]
-		var compRight *Node = node.Children[1]
-
-		// generate left and store result
-		generateComparison(compLeft)
-		var leftPlaceholder *placeholder = &placeholder{[]int{}, []int{}, nil, [2]byte{}}
-		placeholders = append(placeholders, leftPlaceholder)
-		leftPlaceholder.locations = append(leftPlaceholder.locations, curBytePtr+1)
-		addBytes([]byte{0x8D, 0x00, 0x00}) // store accum to temp
-		leftPlaceholder.asmLocations = append(leftPlaceholder.asmLocations, len(curAsm)+4)
-		addAsm("STA _TEMP")
-
-		// generate right and load into X (store in reserved bool spot first)
-		generateComparison(compRight)
-		addBytes([]byte{0x8D, boolMemAddr[0], boolMemAddr[1]}) // store in reserved bool mem loc
-		addAsm("STA $00FF")
-		addBytes([]byte{0xAE, boolMemAddr[0], boolMemAddr[1]}) /// move bool mem addr to X
-		addAsm("LDX $00FF")
-
-		// compare X to leftAddr to set Z
-		leftPlaceholder.locations = append(leftPlaceholder.locations, curBytePtr+1)
-		addBytes([]byte{0xEC, 0x00, 0x00})
-		leftPlaceholder.asmLocations = append(leftPlaceholder.asmLocations, len(curAsm)+4)
-		addAsm("CPX _TEMP")
-
-		var positiveOutcome int = 1
-		var negativeOutcome int = 0
-		if node.Type == "<Inequality>" { // comparison succeeds - we failed
+		c.generateAdd(node)
+	} else if node.Type == "<Subtraction>" {
+		c.generateSub(node)
+	} else if node.Type == "<Conjunction>" || node.Type == "<Disjunction>" {
+		c.generateLogic(node)
+	} else if isBoolOp(node) {
+		if node.Type == "<Negation>" {
+			// compare against true, then it is just !=
+			c.generateComparison(node.Children[0])
+			c.emit(0x8D, scratch())
+			c.emit(0xA2, immediate(0x01))
+			c.emit(0xEC, scratch())
+		} else if c.isString(node.Children[0]) {
+			// same heap address or not, it's the contents that matter
+			c.generateStringCompare(node.Children[0], node.Children[1])
+		} else {
+			var compLeft *Node = node.Children[0]
+			var compRight *Node = node.Children[1]
+			// CPX sets C when X >= the left, so < and >= need the sides the other way around
+			if node.Type == "<LessThan>" || node.Type == "<GreaterOrEqual>" {
+				compLeft, compRight = compRight, compLeft
+			}
+
+			// generate left and store result
+			c.generateComparison(compLeft)
+			var left *slot = c.newSlot(nil, c.curScope.scopeID)
+			c.emit(0x8D, slotOperand(left)) // store accum to temp
+
+			// generate right and load into X (store in reserved bool spot first)
+			c.generateComparison(compRight)
+			c.emit(0x8D, scratch()) // store in reserved bool mem loc
+			c.emit(0xAE, scratch()) // move bool mem addr to X
+
+			// compare X to left to set Z and C
+			c.emit(0xEC, slotOperand(left))
+		}
+
+		var positiveOutcome byte = 1
+		var negativeOutcome byte = 0
+		if node.Type == "<Inequality>" || node.Type == "<Negation>" { // comparison succeeds - we failed
 			positiveOutcome = 0
 			negativeOutcome = 1
 		}
+		var failed byte = 0xD0 // BNE
+		if node.Type == "<LessOrEqual>" || node.Type == "<GreaterOrEqual>" {
+			failed = 0x90 // BCC, X < left
+		} else if node.Type == "<LessThan>" || node.Type == "<GreaterThan>" {
+			failed = 0xB0 // BCS, X >= left
+		}
+		var negative *label = c.newLabel()
+		var done *label = c.newLabel()
 
 		// branch if comparison is false to negative outcome
-		addBytes([]byte{0xD0, 0x0E}) // branch past the loading of positive outcome
-		addAsm("BNE $0E")
+		c.emit(failed, labelOperand(negative))
 
 		// positive outcome
-		zFlagZero() // so we always branch
+		c.zFlagZero() // so we always branch
 		// did Z flag first as to not overwrite result
-		addBytes([]byte{0xA9, byte(positiveOutcome)})
-		addAsm(fmt.Sprintf("LDA #$%02X", uint8(positiveOutcome)))
-		addBytes([]byte{0xD0, 0x02}) // skip the negative outcome
-		addAsm("BNE $02")
+		c.emit(0xA9, immediate(positiveOutcome))
+		c.emit(0xD0, labelOperand(done)) // skip the negative outcome
 
 		// negative outcome
-		addBytes([]byte{0xA9, byte(negativeOutcome)})
-		addAsm(fmt.Sprintf("LDA #$%02X", uint8(negativeOutcome)))
+		c.placeLabel(negative)
+		c.emit(0xA9, immediate(negativeOutcome))
+		c.placeLabel(done)
 	}
 }
 
-func addToHeap(str string) byte {
-	loc, exists := storedStrings[str]
+// && and || only look at the right side if the left did not decide it
+// every way out loads A itself so the left's branch can be fused like an if's
+func (c *Compiler) generateLogic(node *Node) {
+	var short *label = c.newLabel()
+	var done *label = c.newLabel()
+
+	c.generateComparison(node.Children[0])
+	c.emit(0x8D, scratch())
+	c.emit(0xA2, immediate(0x01))
+	c.emit(0xEC, scratch()) // Z is set if the left was true
+	c.emit(0xD0, labelOperand(short))
+
+	if node.Type == "<Conjunction>" {
+		c.generateComparison(node.Children[1])
+		c.alwaysBranch(done)
+		c.placeLabel(short) // false && anything
+		c.emit(0xA9, immediate(0x00))
+	} else {
+		c.emit(0xA9, immediate(0x01)) // true || anything
+		c.alwaysBranch(done)
+		c.placeLabel(short)
+		c.generateComparison(node.Children[1])
+	}
+	c.placeLabel(done)
+}
+
+// branches no matter what without touching A
+func (c *Compiler) alwaysBranch(target *label) {
+	c.emit(0xA2, immediate(0x00))
+	c.emit(0xEC, selfOperand()) // the opcode is never 0
+	c.emit(0xD0, labelOperand(target))
+}
+
+func (c *Compiler) addToHeap(str string) byte {
+	loc, exists := c.storedStrings[str]
 	// if we already have it, just say where
 	if exists {
 		return byte(loc)
 	}
 
-	topHeapPtr--
-	curMem[topHeapPtr] = 0x00 // 0x00 terminated str
-	topHeapPtr -= len(str)    // fills bottom up
-	for i, char := range str {
-		curMem[topHeapPtr+i] = byte(char)
+	if c.topHeapPtr-len(str)-1 < 0 {
+		// the code will not fit either but there is nowhere to even put this
+		if c.genErrors == 0 {
+			c.report(SeverityError, StageCodeGen, CodeGenMemoryExceeded, Location{}, 0, "Memory size exceeded (256 Bytes)", "")
+			c.genErrors++
+		}
+		return 0x00
 	}
-	storedStrings[str] = topHeapPtr // remember we have it stored
 
-	if genErrors == 0 && topHeapPtr <= curBytePtr {
-		Error("Memory size exceeded (256 Bytes)", "CODE GENERATOR")
-		genErrors++
+	c.topHeapPtr--
+	c.curMem[c.topHeapPtr] = 0x00 // 0x00 terminated str
+	c.topHeapPtr -= len(str)      // fills bottom up
+	for i, char := range str {
+		c.curMem[c.topHeapPtr+i] = byte(char)
 	}
-
-	return byte(topHeapPtr)
+	c.storedStrings[str] = c.topHeapPtr // remember we have it stored
+	return byte(c.topHeapPtr)
 }
 
-func GetMachineCode(program int, eightBreaks bool) string {
-	if program < 0 || program > len(memList)-1 {
-		return "Invalid program number"
-	} else if len(memList) == 0 || hadError(program) {
-		return fmt.Sprintf("No machine code generated due to %s error", errorMap[program])
-	}
-
-	var hexString []string
-	if eightBreaks {
-		hexString = append(hexString, " ")
+// the memory image of a program, nil if it never made it through code generation
+func (c *Compiler) GetMemoryImage(program int) *[256]byte {
+	if program < 0 || program > len(c.memList)-1 || c.hadError(program) || len(*c.asmList[program]) == 0 {
+		return nil
 	}
+	return c.memList[program]
+}
 
-	for i, b := range memList[program] {
-		if eightBreaks && i%8 == 0 {
-			hexString = append(hexString, "\n")
-		}
-		hexString = append(hexString, fmt.Sprintf("%02X", b))
+func (c *Compiler) GetMachineCode(program int, eightBreaks bool) string {
+	if program < 0 || program > len(c.memList)-1 {
+		return "Invalid program number"
+	} else if len(c.memList) == 0 || c.hadError(program) {
+		return fmt.Sprintf("No machine code generated due to %s error", c.errorMap[program])
 	}
 
-	return strings.Join(hexString, " ")
+	return hexImage(c.memList[program], eightBreaks)
 }
 
-func GetAssembly(program int) string {
-	if program < 0 || program > len(asmList)-1 {
+func (c *Compiler) GetAssembly(program int) string {
+	if program < 0 || program > len(c.asmList)-1 {
 		return "Invalid program number"
-	} else if len(memList) == 0 || hadError(program) {
-		return fmt.Sprintf("No assembly generated due to %s error", errorMap[program])
+	} else if len(c.memList) == 0 || c.hadError(program) {
+		return fmt.Sprintf("No assembly generated due to %s error", c.errorMap[program])
 	}
 
-	return string(*asmList[program])
+	return string(*c.asmList[program])
 }
@@ -7,169 +7,145 @@ import (
 	"github.com/fatih/color"
 )
 
-var (
+// logging state - every Compiler keeps its own log
+type logState struct {
 	webMode   bool // Flag to toggle between CLI and Web mode
 	Verbose   bool
-	logBuffer string                                // Stores log output for Web mode
-	errorMap  map[int]string = make(map[int]string) // remember if a program had to halt and where
-)
+	logBuffer string         // Stores log output for Web mode
+	errorMap  map[int]string // remember if a program had to halt and where
+}
+
+// A Compiler holds everything a single compilation needs.
+// Each pass keeps its state here instead of in package globals,
+// so separate Compilers can be used at the same time without interfering.
+type Compiler struct {
+	logState
+	lexerState
+	parserState
+	analyzerState
+	codeGenState
+	diagnosticState
+}
+
+// fresh state for one compilation - make a new Compiler per source
+func NewCompiler() *Compiler {
+	var c *Compiler = &Compiler{}
+	c.errorMap = make(map[int]string)
+
+	c.scopePopulation = make(map[int]int)
+	c.propagateUsed = make(map[*SymbolEntry][]*SymbolUsage)
+
+	c.curMem = &([256]byte{}) // New array of 256 bytes, all initialized to 0x00
+	c.topHeapPtr = 255
+	c.storedStrings = make(map[string]int)
+	c.usedScopes = make(map[string]bool)
+	c.firstTime = true
+	return c
+}
 
-func hadError(candidate int) bool {
-	_, exists := errorMap[candidate]
+func (c *Compiler) hadError(candidate int) bool {
+	_, exists := c.errorMap[candidate]
 	return exists
 }
 
-func SetVerbose(toggle bool) {
-	Verbose = toggle
+func (c *Compiler) SetVerbose(toggle bool) {
+	c.Verbose = toggle
 }
 
-func SetWebMode(toggle bool) {
-	webMode = toggle
+func (c *Compiler) SetWebMode(toggle bool) {
+	c.webMode = toggle
 }
 
-func appendLog(msg string) {
-	logBuffer += msg
+func (c *Compiler) appendLog(msg string) {
+	c.logBuffer += msg
 }
 
 // web mode needs html to render
-func Debug(msg string, component string) {
+func (c *Compiler) Debug(msg string, component string) {
 	logMsg := fmt.Sprintf("%-5s | %s --> %s", "DEBUG", component, msg)
-	if webMode && Verbose {
-		appendLog(fmt.Sprintf(`<span class="text-blue-400">%s</span><br>`, html.EscapeString(logMsg)))
-	} else if Verbose {
+	if c.webMode && c.Verbose {
+		c.appendLog(fmt.Sprintf(`<span class="text-blue-400">%s</span><br>`, html.EscapeString(logMsg)))
+	} else if c.Verbose {
 		fmt.Print(color.BlueString(logMsg + "\n"))
 	}
 }
 
-func Error(msg string, component string) {
+func (c *Compiler) Error(msg string, component string) {
 	logMsg := fmt.Sprintf("%-5s | %s --> %s", "ERROR", component, msg)
-	if webMode {
-		appendLog(fmt.Sprintf(`<span class="text-red-400">%s</span><br>`, html.EscapeString(logMsg)))
+	if c.webMode {
+		c.appendLog(fmt.Sprintf(`<span class="text-red-400">%s</span><br>`, html.EscapeString(logMsg)))
 	} else {
 		color.Red(logMsg)
 	}
 }
 
-func Warn(msg string, component string) {
+func (c *Compiler) Warn(msg string, component string) {
 	logMsg := fmt.Sprintf("%-5s | %s --> %s", "WARN", component, msg)
-	if webMode {
-		appendLog(fmt.Sprintf(`<span class="text-yellow-400">%s</span><br>`, html.EscapeString(logMsg)))
+	if c.webMode {
+		c.appendLog(fmt.Sprintf(`<span class="text-yellow-400">%s</span><br>`, html.EscapeString(logMsg)))
 	} else {
 		color.Yellow(logMsg)
 	}
 }
 
-func Pass(msg string, component string) {
+func (c *Compiler) Pass(msg string, component string) {
 	logMsg := fmt.Sprintf("%-5s | %s --> %s", "PASS", component, msg)
-	if webMode {
-		appendLog(fmt.Sprintf(`<span class="text-green-400">%s</span><br>`, html.EscapeString(logMsg)))
+	if c.webMode {
+		c.appendLog(fmt.Sprintf(`<span class="text-green-400">%s</span><br>`, html.EscapeString(logMsg)))
 	} else {
 		color.Green(logMsg)
 	}
 }
 
-func Fail(msg string, component string) {
+func (c *Compiler) Fail(msg string, component string) {
 	logMsg := fmt.Sprintf("%-5s | %s --> %s", "FAIL", component, msg)
-	if webMode {
-		appendLog(fmt.Sprintf(`<span class="text-red-500">%s</span><br>`, html.EscapeString(logMsg)))
+	if c.webMode {
+		c.appendLog(fmt.Sprintf(`<span class="text-red-500">%s</span><br>`, html.EscapeString(logMsg)))
 	} else {
 		color.Red(logMsg)
 	}
 }
 
-func Info(msg string, component string, space bool) {
+func (c *Compiler) Info(msg string, component string, space bool) {
 	logMsg := fmt.Sprintf("%-5s | %s --> %s", "INFO", component, msg)
 	if space {
 		logMsg = "\n" + logMsg
 	}
 
-	if webMode {
-		appendLog(fmt.Sprintf(`<span class="text-white">%s</span><br>`, html.EscapeString(logMsg)))
+	if c.webMode {
+		c.appendLog(fmt.Sprintf(`<span class="text-white">%s</span><br>`, html.EscapeString(logMsg)))
 	} else {
 		fmt.Print(logMsg + "\n")
 	}
 }
 
-func CriticalError(location string, err interface{}) {
+func (c *Compiler) CriticalError(location string, err interface{}) {
 	errorMsg := fmt.Sprintf("DEFEAT | GOPILER --> Congratulations grand wizard. You have truly bested me.\n"+
 		"\tYour code caused a critical error in the %s: %v\n", location, err)
-	if webMode {
-		appendLog(fmt.Sprintf(`<span class="text-white">%s</span><br>`, errorMsg))
+	if c.webMode {
+		c.appendLog(fmt.Sprintf(`<span class="text-white">%s</span><br>`, errorMsg))
 	} else {
 		color.Red(errorMsg)
 	}
 }
 
 // Retrieve log output for web responses
-func GetLogOutput() string {
-	output := logBuffer
-	logBuffer = "" // Clear after reading
+func (c *Compiler) GetLogOutput() string {
+	output := c.logBuffer
+	c.logBuffer = "" // Clear after reading
 	return output
 }
 
-// erase everything between compiles in webapp just in case
-func ResetAll() {
-	logBuffer = ""
-	tokens = nil
-	liveTokenIdx = 0
-	liveToken = Token{}
-	parseError = false
-	alternateWarning = ""
-	pNum = 0
-	currentParent = nil
-	cstList = nil
-
-	astList = nil
-	astStrings = nil
-	curAst = nil
-	curParent = nil
-	parentStack = nil
-	stringBuffer = nil
-	symbolTableTreeList = nil
-	curSymbolTableTree = nil
-	curSymbolTable = nil
-
-	errorCount = 0
-	inAssign = false
-	assignParent = nil
-	assignParentScope = ""
-	propagateUsed = make(map[*SymbolEntry][]*SymbolUsage)
-	// used for re-init before use in case self used (earlier deps no longer unused!)
-	dependencyArtifact = nil
-	warnCount = 0
-	scopeDepth = 0
-	scopePopulation = make(map[int]int)
-	errorMap = make(map[int]string)
-
-	printTreeBuffer = ""
-
-	memList = []*([256]byte){}
-	curMem = &([256]byte{}) // New array of 256 bytes, all initialized to 0x00
-	asmList = []*[]byte{}
-	curAsm = []byte{}
-	curBytePtr = 0
-	placeholders = []*placeholder{}
-	curScope = nil
-	genErrors = 0
-	genWarns = 0
-	endStackPtr = 0
-	topHeapPtr = 255
-	boolMemAddr = [2]byte{0xFF, 0x00}
-	storedStrings = make(map[string]int)
-	usedScopes = make(map[string]bool)
-	firstTime = true
-}
-
-func CreateFailedProgramVars(pNum int, failPoint string) {
+func (c *Compiler) CreateFailedProgramVars(pNum int, failPoint string) {
 	switch failPoint {
 	case "lexer":
-		startCst(pNum)
+		c.startCst(pNum)
 		fallthrough
 	case "parser":
-		initAst(pNum)
-		astStrings = append(astStrings, "Error")
+		c.initAst(pNum)
+		c.astStrings[pNum] = "Error"
 		fallthrough
 	case "semantic":
-		initMem(pNum)
+		c.initMem(pNum)
 	}
 }
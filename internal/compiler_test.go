@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Compilers share nothing, so any number can compile at once (run with -race)
+func TestConcurrentCompilers(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		i := i
+		t.Run(fmt.Sprintf("compiler %d", i), func(t *testing.T) {
+			t.Parallel()
+			var want string = strings.Repeat(fmt.Sprint(i%10), i%4+1)
+			var src string = fmt.Sprintf(`{int a a = 0 int n n = %d while (a != %d) {print(n) a = 1 + a} print("x")}$
+{string s s = "p%c" print(s)}$`, i%10, i%4+1, 'a'+rune(i))
+
+			var c *Compiler = NewCompiler()
+			c.SetVerbose(false)
+			c.SetWebMode(true)
+			c.Compile(src, StageCodeGen)
+			if got, _ := c.Run(0, 10000); got != want+"x" {
+				t.Errorf("program 1 printed %q, want %q", got, want+"x")
+			}
+			if got, _ := c.Run(1, 10000); got != fmt.Sprintf("p%c", 'a'+rune(i)) {
+				t.Errorf("program 2 printed %q, want %q", got, fmt.Sprintf("p%c", 'a'+rune(i)))
+			}
+			if !strings.Contains(c.GetAst(), fmt.Sprintf("{DIGIT [ %d ]}", i%10)) {
+				t.Errorf("AST is not this compiler's:\n%s", c.GetAst())
+			}
+		})
+	}
+}
+
+// trees handed out for one program stay the program's trees while the next ones are built
+func TestTreesOutliveLaterPrograms(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	programs, _ := c.Lex("{print(1)}$ {print(2)}$ {print(3)}$ {print(4)}$ {print(5)}$")
+
+	var csts, asts []*TokenTree
+	for pNum, tokens := range programs {
+		cst, _ := c.Parse(tokens, pNum)
+		csts = append(csts, cst)
+		ast, _ := c.BuildAST(cst, pNum)
+		asts = append(asts, ast)
+	}
+	for pNum := range programs {
+		if csts[pNum] != c.cstList[pNum] || asts[pNum] != c.astList[pNum] {
+			t.Errorf("program %d's trees were moved after they were returned", pNum+1)
+		}
+		if !strings.Contains(asts[pNum].drawTree(), fmt.Sprintf("[ %d ]", pNum+1)) {
+			t.Errorf("program %d's AST is another program's:\n%s", pNum+1, asts[pNum].drawTree())
+		}
+	}
+}
+
+// Compile gives back what each pass returned, which is everything the passes reported
+func TestCompileReturnsEveryPassDiagnostics(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	var diags []Diagnostic = c.Compile(`{int a print(b)}$ {int c c = 200 + 100 print(c)}$ {print(}$ {int d d = @}$`, StageCodeGen)
+
+	var codes []string
+	for _, diag := range diags {
+		codes = append(codes, diag.Code)
+	}
+	var want []string = []string{CodeLexInvalidChar, CodeSemUndeclared, CodeSemNeverInit,
+		CodeGenIntWrap, CodeParseUnexpectedToken}
+	if strings.Join(codes, " ") != strings.Join(want, " ") {
+		t.Errorf("Compile returned %v, want %v", codes, want)
+	}
+	if len(diags) != len(c.Diagnostics()) {
+		t.Errorf("Compile returned %d diagnostics, the Compiler has %d", len(diags), len(c.Diagnostics()))
+	}
+}
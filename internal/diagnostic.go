@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "ERROR"
+	SeverityWarning Severity = "WARN"
+)
+
+// which pass produced a diagnostic (matches the log component names)
+type Stage string
+
+const (
+	StageLexer    Stage = "LEXER"
+	StageParser   Stage = "PARSER"
+	StageSemantic Stage = "SEMANTIC ANALYZER"
+	StageCodeGen  Stage = "CODE GENERATOR"
+	StageRuntime  Stage = "EMULATOR" // running generated code, not a compiler pass
+)
+
+// Stable diagnostic codes - tools match on these, so never rename one
+const (
+	CodeLexMultilineString    = "LEX-MULTILINE-STRING"
+	CodeLexInvalidStringChar  = "LEX-INVALID-STRING-CHAR"
+	CodeLexInvalidChar        = "LEX-INVALID-CHAR"
+	CodeLexUnterminatedString = "LEX-UNTERMINATED-STRING"
+	CodeLexUntermComment      = "LEX-UNTERMINATED-COMMENT"
+	CodeLexMissingEOP         = "LEX-MISSING-EOP"
+	CodeLexNoTokens           = "LEX-NO-TOKENS"
+
+	CodeParseUnexpectedToken = "PARSE-UNEXPECTED-TOKEN"
+
+	CodeSemUndeclared    = "SEM-UNDECLARED"
+	CodeSemRedeclared    = "SEM-REDECLARED"
+	CodeSemTypeMismatch  = "SEM-TYPE-MISMATCH"
+	CodeSemUninitialized = "SEM-UNINITIALIZED-USE"
+	CodeSemNeverInit     = "SEM-NEVER-INITIALIZED"
+	CodeSemUnused        = "SEM-UNUSED"
+	CodeSemInfiniteLoop  = "SEM-INFINITE-LOOP"
+	CodeSemIntRange      = "SEM-INT-RANGE"
+
+	CodeGenMemoryExceeded = "GEN-MEMORY-EXCEEDED"
+	CodeGenEarlyRedeclUse = "GEN-EARLY-REDECL-USE"
+	CodeGenIntWrap        = "GEN-INT-WRAP"
+	CodeGenUnknownSymbol  = "GEN-UNKNOWN-SYMBOL"
+
+	CodeRunStepLimit = "RUN-STEP-LIMIT"
+	CodeRunFault     = "RUN-FAULT"
+)
+
+// line and column in the source, both counted from 1 (0 means unknown)
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// A Diagnostic is one error or warning found while compiling.
+// End is exclusive: it is the column just past the offending text.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Stage    Stage    `json:"stage"`
+	Code     string   `json:"code"`
+	Program  int      `json:"program"` // indexed from 0 like everything else
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint,omitempty"`
+}
+
+type diagnosticState struct {
+	diagnostics []Diagnostic
+	curProgram  int // program the passes are currently working on
+}
+
+// human readable form used by the console and html loggers
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	sb.WriteString(d.Message)
+	if d.Start.Line > 0 {
+		sb.WriteString(fmt.Sprintf(" at (%d:%d)", d.Start.Line, d.Start.Column))
+	}
+	if d.Hint != "" {
+		sb.WriteString("; Hint: " + d.Hint)
+	}
+	sb.WriteString(fmt.Sprintf(" [%s]", d.Code))
+	return sb.String()
+}
+
+// record a diagnostic and log it
+// width is how many columns the offending text covers from loc
+func (c *Compiler) report(severity Severity, stage Stage, code string, loc Location, width int,
+	msg string, hint string) {
+	var diag Diagnostic = Diagnostic{
+		Severity: severity,
+		Stage:    stage,
+		Code:     code,
+		Program:  c.curProgram,
+		Start:    Position{Line: loc.line, Column: loc.startPos},
+		End:      Position{Line: loc.line, Column: loc.startPos + width},
+		Message:  msg,
+		Hint:     hint,
+	}
+	c.diagnostics = append(c.diagnostics, diag)
+
+	if severity == SeverityError {
+		c.Error(diag.String(), string(stage))
+	} else {
+		c.Warn(diag.String(), string(stage))
+	}
+}
+
+// every diagnostic from every program so far, in the order they were found
+func (c *Compiler) Diagnostics() []Diagnostic {
+	return c.diagnostics
+}
+
+// width of a token in the source for diagnostic spans
+func tokenWidth(token *Token) int {
+	return len([]rune(token.trueContent))
+}
+
+// diagnostics reported since the given count, so each pass can return its own
+func (c *Compiler) diagnosticsSince(start int) []Diagnostic {
+	return append([]Diagnostic{}, c.diagnostics[start:]...)
+}
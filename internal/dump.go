@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+/* JSON output.
+Everything the passes produce for a program, in a form scripts can read instead of
+scraping the logs. Tokens, trees, and symbol tables marshal themselves; what a failed
+pass never produced (or freed) comes out as null. */
+
+// A ProgramDump is every artifact of one program.
+type ProgramDump struct {
+	Program     int          `json:"program"` // indexed from 0 like everything else
+	Tokens      []Token      `json:"tokens"`
+	CST         *Node        `json:"cst"`
+	AST         *Node        `json:"ast"`
+	Symbols     *SymbolTable `json:"symbols"`
+	Assembly    []string     `json:"assembly"`
+	Memory      *[256]byte   `json:"memory"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Output      *string      `json:"output,omitempty"` // what it printed, if whoever compiled it also ran it
+}
+
+// Dump collects the artifacts of every program in the last compilation
+func (c *Compiler) Dump() []ProgramDump {
+	var dumps []ProgramDump
+	for program := 0; program < c.programCount; program++ {
+		var diags []Diagnostic = []Diagnostic{}
+		for _, diag := range c.diagnostics {
+			if diag.Program == program {
+				diags = append(diags, diag)
+			}
+		}
+		dumps = append(dumps, ProgramDump{
+			Program:     program,
+			Tokens:      c.TokenStream(program),
+			CST:         c.CstTree(program),
+			AST:         c.AstTree(program),
+			Symbols:     c.SymbolTree(program),
+			Assembly:    c.AssemblyLines(program),
+			Memory:      c.GetMemoryImage(program),
+			Diagnostics: diags,
+		})
+	}
+	return dumps
+}
+
+// a program's tokens, even if lexing failed
+func (c *Compiler) TokenStream(program int) []Token {
+	if program < 0 || program >= len(c.tokenList) {
+		return nil
+	}
+	return c.tokenList[program]
+}
+
+// the root of a program's CST, nil if parsing failed
+func (c *Compiler) CstTree(program int) *Node {
+	if program < 0 || program >= len(c.cstList) {
+		return nil
+	}
+	return c.cstList[program].rootNode
+}
+
+// the root of a program's AST, nil if parsing failed (it is kept through a failed analysis)
+func (c *Compiler) AstTree(program int) *Node {
+	if program < 0 || program >= len(c.astList) {
+		return nil
+	}
+	return c.astList[program].rootNode
+}
+
+// the outermost scope of a program, nil if analysis failed
+func (c *Compiler) SymbolTree(program int) *SymbolTable {
+	if program < 0 || program >= len(c.symbolTableTreeList) {
+		return nil
+	}
+	return c.symbolTableTreeList[program].rootTable
+}
+
+// a program's assembly an instruction a line, nil if code generation failed
+func (c *Compiler) AssemblyLines(program int) []string {
+	if c.GetMemoryImage(program) == nil {
+		return nil
+	}
+	var listing string = strings.TrimPrefix(string(*c.asmList[program]), asmHeader)
+	var lines []string
+	for _, line := range strings.Split(listing, asmSeparator) {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func (token Token) Type() TokenType           { return token.tType }
+func (token Token) Content() string           { return token.content }
+func (token Token) TrueContent() string       { return token.trueContent }
+func (token Token) Location() Location        { return token.location }
+func (loc Location) Line() int                { return loc.line }
+func (loc Location) Column() int              { return loc.startPos }
+func (entry *SymbolEntry) Name() string       { return entry.name }
+func (entry *SymbolEntry) DataType() string   { return entry.dataType }
+func (entry *SymbolEntry) Position() Location { return entry.position }
+func (entry *SymbolEntry) IsInit() bool       { return entry.isInit }
+func (entry *SymbolEntry) BeenUsed() bool     { return entry.beenUsed }
+func (table *SymbolTable) ScopeID() string    { return table.scopeID }
+
+// a scope's entries, alphabetized
+func (table *SymbolTable) Entries() []*SymbolEntry {
+	return table.sortedEntries()
+}
+
+// the scopes opened directly inside this one
+func (table *SymbolTable) SubTables() []*SymbolTable {
+	return table.subTables
+}
+
+func (token Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type        TokenType `json:"type"`
+		Content     string    `json:"content"`
+		TrueContent string    `json:"trueContent"`
+		Line        int       `json:"line"`
+		Column      int       `json:"column"`
+	}{token.tType, token.content, token.trueContent, token.location.line, token.location.startPos})
+}
+
+// terminals have a token, everything else has children
+func (node *Node) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string  `json:"type"`
+		Token    *Token  `json:"token,omitempty"`
+		Children []*Node `json:"children,omitempty"`
+	}{node.Type, node.Token, node.Children})
+}
+
+func (entry *SymbolEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+		IsInit   bool   `json:"isInit"`
+		BeenUsed bool   `json:"beenUsed"`
+	}{entry.name, entry.dataType, entry.position.line, entry.position.startPos, entry.isInit, entry.beenUsed})
+}
+
+func (table *SymbolTable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Scope     string         `json:"scope"`
+		Entries   []*SymbolEntry `json:"entries"`
+		SubTables []*SymbolTable `json:"subTables"`
+	}{table.scopeID, table.sortedEntries(), append([]*SymbolTable{}, table.subTables...)})
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// the shape scripts rely on, read back without any of our types
+type dumpShape struct {
+	Program int `json:"program"`
+	Tokens  []struct {
+		Type        string `json:"type"`
+		Content     string `json:"content"`
+		TrueContent string `json:"trueContent"`
+		Line        int    `json:"line"`
+		Column      int    `json:"column"`
+	} `json:"tokens"`
+	CST *struct {
+		Type     string            `json:"type"`
+		Children []json.RawMessage `json:"children"`
+	} `json:"cst"`
+	AST     json.RawMessage `json:"ast"`
+	Symbols *struct {
+		Scope   string `json:"scope"`
+		Entries []struct {
+			Name     string `json:"name"`
+			Type     string `json:"type"`
+			IsInit   bool   `json:"isInit"`
+			BeenUsed bool   `json:"beenUsed"`
+		} `json:"entries"`
+		SubTables []json.RawMessage `json:"subTables"`
+	} `json:"symbols"`
+	Assembly    []string     `json:"assembly"`
+	Memory      []int        `json:"memory"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func TestDumpJSON(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetWebMode(true)
+	c.Compile("{int a a = 2 { print(a) }}$ {print(b)}$", StageCodeGen)
+
+	encoded, err := json.Marshal(c.Dump())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dumps []dumpShape
+	if err := json.Unmarshal(encoded, &dumps); err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 2 {
+		t.Fatalf("expected 2 programs, got %d", len(dumps))
+	}
+
+	var ok dumpShape = dumps[0]
+	if tok := ok.Tokens[1]; tok.Type != "keyword" || tok.Content != "I_TYPE" || tok.TrueContent != "int" || tok.Line != 1 || tok.Column != 2 {
+		t.Errorf("second token is %+v", tok)
+	}
+	if ok.CST == nil || ok.CST.Type != "<Program>" || len(ok.CST.Children) != 2 {
+		t.Errorf("CST root is %+v", ok.CST)
+	}
+	if ok.Symbols == nil || len(ok.Symbols.Entries) != 1 || !ok.Symbols.Entries[0].IsInit || !ok.Symbols.Entries[0].BeenUsed ||
+		len(ok.Symbols.SubTables) != 1 {
+		t.Errorf("symbols are %+v", ok.Symbols)
+	}
+	if len(ok.Assembly) == 0 || ok.Assembly[0] != "LDA #$00" || len(ok.Memory) != 256 || ok.Memory[0] != 0xA9 {
+		t.Errorf("assembly starts %q and memory has %d bytes", ok.Assembly, len(ok.Memory))
+	}
+
+	// b is undeclared, so there is an AST but nothing after it
+	var failed dumpShape = dumps[1]
+	if failed.Program != 1 || string(failed.AST) == "null" || failed.Symbols != nil || failed.Assembly != nil || failed.Memory != nil {
+		t.Errorf("failed program dumped as %+v", failed)
+	}
+	if len(failed.Diagnostics) == 0 || failed.Diagnostics[0].Code != CodeSemUndeclared {
+		t.Errorf("failed program has diagnostics %+v", failed.Diagnostics)
+	}
+}
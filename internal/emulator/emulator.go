@@ -0,0 +1,192 @@
+package emulator
+
+/* A tiny 6502 for running the images the code generator produces.
+It only knows the opcodes the code generator emits, and it follows the class OS:
+memory is 256 bytes, only CPX touches the Z flag, and SYS ($FF) prints. */
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// memory size of the target machine
+const MemorySize int = 256
+
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// opcodes we can run and how many operand bytes each takes
+var operandBytes = map[byte]int{
+	0xA9: 1, // LDA constant
+	0xAD: 2, // LDA memory
+	0x8D: 2, // STA memory
+	0x6D: 2, // ADC memory
+	0xED: 2, // SBC memory
+	0x38: 0, // SEC
+	0xA2: 1, // LDX constant
+	0xAE: 2, // LDX memory
+	0xA0: 1, // LDY constant
+	0xAC: 2, // LDY memory
+	0xEA: 0, // NOP
+	0x00: 0, // BRK
+	0xEC: 2, // CPX memory
+	0xD0: 1, // BNE
+	0x90: 1, // BCC
+	0xB0: 1, // BCS
+	0xEE: 2, // INC memory
+	0xFF: 0, // SYS
+}
+
+// how many operand bytes follow an opcode, false if we cannot run it
+func OperandBytes(opcode byte) (int, bool) {
+	size, known := operandBytes[opcode]
+	return size, known
+}
+
+var Mnemonics = map[byte]string{
+	0xA9: "LDA", 0xAD: "LDA", 0x8D: "STA", 0x6D: "ADC", 0xED: "SBC", 0x38: "SEC",
+	0xA2: "LDX", 0xAE: "LDX", 0xA0: "LDY", 0xAC: "LDY",
+	0xEA: "NOP", 0x00: "BRK", 0xEC: "CPX", 0xD0: "BNE", 0x90: "BCC", 0xB0: "BCS",
+	0xEE: "INC", 0xFF: "SYS",
+}
+
+type CPU struct {
+	A      byte // accumulator
+	X      byte
+	Y      byte
+	PC     byte // program counter - memory is only 256 bytes
+	Zero   bool // Z flag, set by CPX when X matches memory
+	Carry  bool // C flag, set by CPX when X >= memory, by ADC on overflow, and by SBC when nothing was borrowed
+	Memory [MemorySize]byte
+	Steps  int // instructions executed so far
+	Halted bool
+
+	output strings.Builder
+}
+
+// new machine with the image loaded at $0000
+func New(image [MemorySize]byte) *CPU {
+	return &CPU{Memory: image}
+}
+
+// everything printed by SYS so far
+func (cpu *CPU) Output() string {
+	return cpu.output.String()
+}
+
+// operand bytes are little endian; the high byte must be $00 on this machine
+func (cpu *CPU) address(opPC byte) (byte, error) {
+	var low byte = cpu.Memory[opPC+1]
+	var high byte = cpu.Memory[opPC+2]
+	if high != 0x00 {
+		return 0, fmt.Errorf("address $%02X%02X at $%02X is outside of memory", high, low, opPC)
+	}
+	return low, nil
+}
+
+// execute one instruction
+func (cpu *CPU) Step() error {
+	if cpu.Halted {
+		return nil
+	}
+
+	var opPC byte = cpu.PC
+	var opcode byte = cpu.Memory[opPC]
+	size, known := operandBytes[opcode]
+	if !known {
+		return fmt.Errorf("invalid opcode $%02X at $%02X", opcode, opPC)
+	}
+
+	var addr byte
+	if size == 2 {
+		var err error
+		if addr, err = cpu.address(opPC); err != nil {
+			return err
+		}
+	}
+	var constant byte = cpu.Memory[opPC+1]
+	cpu.PC = opPC + 1 + byte(size) // wraps like the real thing
+	cpu.Steps++
+
+	switch opcode {
+	case 0xA9:
+		cpu.A = constant
+	case 0xAD:
+		cpu.A = cpu.Memory[addr]
+	case 0x8D:
+		cpu.Memory[addr] = cpu.A
+	case 0x6D:
+		var sum int = int(cpu.A) + int(cpu.Memory[addr])
+		cpu.Carry = sum > 0xFF
+		cpu.A = byte(sum)
+	case 0xED:
+		var difference int = int(cpu.A) - int(cpu.Memory[addr])
+		if !cpu.Carry { // the carry is the inverse of a borrow
+			difference--
+		}
+		cpu.Carry = difference >= 0
+		cpu.A = byte(difference)
+	case 0x38:
+		cpu.Carry = true
+	case 0xA2:
+		cpu.X = constant
+	case 0xAE:
+		cpu.X = cpu.Memory[addr]
+	case 0xA0:
+		cpu.Y = constant
+	case 0xAC:
+		cpu.Y = cpu.Memory[addr]
+	case 0xEA:
+		// nothing
+	case 0x00:
+		cpu.Halted = true
+	case 0xEC:
+		cpu.Zero = cpu.X == cpu.Memory[addr]
+		cpu.Carry = cpu.X >= cpu.Memory[addr]
+	case 0xD0:
+		if !cpu.Zero {
+			cpu.PC += constant // 2's comp offset wraps backwards
+		}
+	case 0x90:
+		if !cpu.Carry {
+			cpu.PC += constant
+		}
+	case 0xB0:
+		if cpu.Carry {
+			cpu.PC += constant
+		}
+	case 0xEE:
+		cpu.Memory[addr]++
+	case 0xFF:
+		cpu.sysCall()
+	}
+	return nil
+}
+
+// X = 1: print Y as an integer
+// X = 2: print the 0x00 terminated string starting at Y
+func (cpu *CPU) sysCall() {
+	switch cpu.X {
+	case 0x01:
+		cpu.output.WriteString(strconv.Itoa(int(cpu.Y)))
+	case 0x02:
+		for i := int(cpu.Y); i < MemorySize && cpu.Memory[i] != 0x00; i++ {
+			cpu.output.WriteByte(cpu.Memory[i])
+		}
+	}
+}
+
+// Run executes until BRK, an error, or maxSteps instructions (no limit if maxSteps <= 0).
+// Returns everything the program printed.
+func (cpu *CPU) Run(maxSteps int) (string, error) {
+	for !cpu.Halted {
+		if maxSteps > 0 && cpu.Steps >= maxSteps {
+			return cpu.Output(), ErrStepLimit
+		}
+		if err := cpu.Step(); err != nil {
+			return cpu.Output(), err
+		}
+	}
+	return cpu.Output(), nil
+}
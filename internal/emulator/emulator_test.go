@@ -0,0 +1,168 @@
+package emulator
+
+import (
+	"errors"
+	"testing"
+)
+
+// a machine with code at $0000 and data poked in after
+func machine(code []byte, data map[byte]byte) *CPU {
+	var image [MemorySize]byte
+	copy(image[:], code)
+	for addr, value := range data {
+		image[addr] = value
+	}
+	return New(image)
+}
+
+// runs code to its BRK
+func run(t *testing.T, code []byte, data map[byte]byte) *CPU {
+	t.Helper()
+	var cpu *CPU = machine(code, data)
+	if _, err := cpu.Run(1000); err != nil {
+		t.Fatalf("% X: %v", code, err)
+	}
+	return cpu
+}
+
+func TestLoadsAndStores(t *testing.T) {
+	var cpu *CPU = run(t, []byte{
+		0xA9, 0x11, // LDA #$11
+		0x8D, 0xF0, 0x00, // STA $00F0
+		0xAD, 0xF1, 0x00, // LDA $00F1
+		0xA2, 0x22, // LDX #$22
+		0xAE, 0xF2, 0x00, // LDX $00F2
+		0xA0, 0x33, // LDY #$33
+		0xAC, 0xF0, 0x00, // LDY $00F0
+		0xEE, 0xF3, 0x00, // INC $00F3
+		0xEE, 0xF4, 0x00, // INC $00F4
+		0xEA, // NOP
+		0x00, // BRK
+	}, map[byte]byte{0xF1: 0x44, 0xF2: 0x55, 0xF3: 0x09, 0xF4: 0xFF})
+
+	if cpu.A != 0x44 || cpu.X != 0x55 || cpu.Y != 0x11 {
+		t.Errorf("A X Y = %02X %02X %02X, want 44 55 11", cpu.A, cpu.X, cpu.Y)
+	}
+	if cpu.Memory[0xF0] != 0x11 || cpu.Memory[0xF3] != 0x0A || cpu.Memory[0xF4] != 0x00 {
+		t.Errorf("memory F0 F3 F4 = %02X %02X %02X, want 11 0A 00", cpu.Memory[0xF0], cpu.Memory[0xF3], cpu.Memory[0xF4])
+	}
+	if !cpu.Halted || cpu.Steps != 11 || cpu.PC != 0x1A {
+		t.Errorf("halted %v after %d steps at $%02X, want true 11 $1A", cpu.Halted, cpu.Steps, cpu.PC)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	var tests = []struct {
+		name  string
+		code  []byte
+		a     byte
+		zero  bool
+		carry bool
+	}{
+		{"CPX equal", []byte{0xA2, 0x05, 0xEC, 0xF0, 0x00}, 0, true, true},
+		{"CPX greater", []byte{0xA2, 0x06, 0xEC, 0xF0, 0x00}, 0, false, true},
+		{"CPX less", []byte{0xA2, 0x04, 0xEC, 0xF0, 0x00}, 0, false, false},
+		{"ADC no carry", []byte{0xA9, 0x03, 0x6D, 0xF0, 0x00}, 0x08, false, false},
+		// only CPX sets Z, even when the sum is 0
+		{"ADC carry", []byte{0xA9, 0xFB, 0x6D, 0xF0, 0x00}, 0x00, false, true},
+		// and the carry does not go in
+		{"ADC ignores carry", []byte{0x38, 0xA9, 0x01, 0x6D, 0xF0, 0x00}, 0x06, false, false},
+		{"SBC no borrow", []byte{0x38, 0xA9, 0x07, 0xED, 0xF0, 0x00}, 0x02, false, true},
+		{"SBC to 0", []byte{0x38, 0xA9, 0x05, 0xED, 0xF0, 0x00}, 0x00, false, true},
+		{"SBC borrow", []byte{0x38, 0xA9, 0x03, 0xED, 0xF0, 0x00}, 0xFE, false, false},
+		{"SBC without SEC", []byte{0xA9, 0x07, 0xED, 0xF0, 0x00}, 0x01, false, true},
+		{"Z is kept", []byte{0xA2, 0x05, 0xEC, 0xF0, 0x00, 0xA9, 0x01, 0x6D, 0xF0, 0x00}, 0x06, true, false},
+	}
+	for _, test := range tests {
+		var cpu *CPU = run(t, append(test.code, 0x00), map[byte]byte{0xF0: 0x05})
+		if cpu.A != test.a || cpu.Zero != test.zero || cpu.Carry != test.carry {
+			t.Errorf("%s: A %02X Z %v C %v, want %02X %v %v", test.name, cpu.A, cpu.Zero, cpu.Carry, test.a, test.zero, test.carry)
+		}
+	}
+}
+
+func TestBranches(t *testing.T) {
+	var tests = []struct {
+		name string
+		code []byte
+		want string
+	}{
+		// X = 1 vs $F0 = 5 clears Z and C; each prints 1 if it branched over the LDY #$02
+		{"BNE taken", []byte{0xA2, 0x01, 0xEC, 0xF0, 0x00, 0xA0, 0x01, 0xD0, 0x02, 0xA0, 0x02, 0xA2, 0x01, 0xFF}, "1"},
+		{"BNE not taken", []byte{0xA2, 0x05, 0xEC, 0xF0, 0x00, 0xA0, 0x01, 0xD0, 0x02, 0xA0, 0x02, 0xA2, 0x01, 0xFF}, "2"},
+		{"BCC taken", []byte{0xA2, 0x01, 0xEC, 0xF0, 0x00, 0xA0, 0x01, 0x90, 0x02, 0xA0, 0x02, 0xA2, 0x01, 0xFF}, "1"},
+		{"BCC not taken", []byte{0xA2, 0x06, 0xEC, 0xF0, 0x00, 0xA0, 0x01, 0x90, 0x02, 0xA0, 0x02, 0xA2, 0x01, 0xFF}, "2"},
+		{"BCS taken", []byte{0xA2, 0x06, 0xEC, 0xF0, 0x00, 0xA0, 0x01, 0xB0, 0x02, 0xA0, 0x02, 0xA2, 0x01, 0xFF}, "1"},
+		{"BCS not taken", []byte{0xA2, 0x01, 0xEC, 0xF0, 0x00, 0xA0, 0x01, 0xB0, 0x02, 0xA0, 0x02, 0xA2, 0x01, 0xFF}, "2"},
+		// counts $F1 up to 3: $F0 is -16, back to the INC
+		{"backwards", []byte{0xEE, 0xF1, 0x00, 0xA2, 0x01, 0xAC, 0xF1, 0x00, 0xFF, 0xA2, 0x03, 0xEC, 0xF1, 0x00, 0xD0, 0xF0}, "123"},
+	}
+	for _, test := range tests {
+		var cpu *CPU = run(t, append(test.code, 0x00), map[byte]byte{0xF0: 0x05})
+		if cpu.Output() != test.want {
+			t.Errorf("%s: printed %q, want %q", test.name, cpu.Output(), test.want)
+		}
+	}
+}
+
+// a branch past $FF comes back around from $00
+func TestBranchWrapsAroundMemory(t *testing.T) {
+	var cpu *CPU = machine(nil, map[byte]byte{
+		0x00: 0x00,             // BRK
+		0xF0: 0xA2, 0xF1: 0x01, // LDX #$01
+		0xF2: 0xEC, 0xF3: 0xFE, 0xF4: 0x00, // CPX $00FE (which is 0)
+		0xF5: 0xD0, 0xF6: 0x09, // BNE to $F7 + 9 = $00
+	})
+	cpu.PC = 0xF0
+	if _, err := cpu.Run(10); err != nil || !cpu.Halted || cpu.Steps != 4 || cpu.PC != 0x01 {
+		t.Errorf("halted %v after %d steps at $%02X (%v), want the BRK at $00", cpu.Halted, cpu.Steps, cpu.PC, err)
+	}
+}
+
+func TestSys(t *testing.T) {
+	var cpu *CPU = run(t, []byte{
+		0xA2, 0x01, 0xA0, 0xFF, 0xFF, // print 255
+		0xA0, 0x00, 0xFF, // print 0
+		0xA2, 0x02, 0xA0, 0xF0, 0xFF, // print the string at $F0
+		0xA2, 0x03, 0xFF, // nothing else prints
+		0x00,
+	}, map[byte]byte{0xF0: 'h', 0xF1: 'i', 0xF2: ' ', 0xF3: 0x00, 0xF4: 'x'})
+	if cpu.Output() != "2550hi " {
+		t.Errorf("printed %q, want %q", cpu.Output(), "2550hi ")
+	}
+
+	// a string with no end stops at the end of memory
+	cpu = run(t, []byte{0xA2, 0x02, 0xA0, 0xFE, 0xFF, 0x00}, map[byte]byte{0xFE: 'o', 0xFF: 'k'})
+	if cpu.Output() != "ok" {
+		t.Errorf("printed %q, want %q", cpu.Output(), "ok")
+	}
+}
+
+func TestRunStepLimit(t *testing.T) {
+	// LDX #$01, CPX $00FF (0), BNE back to the CPX forever
+	var cpu *CPU = machine([]byte{0xA2, 0x01, 0xEC, 0xFF, 0x00, 0xD0, 0xFB}, nil)
+	output, err := cpu.Run(100)
+	if !errors.Is(err, ErrStepLimit) || cpu.Steps != 100 || output != "" || cpu.Halted {
+		t.Errorf("ran %d steps, halted %v, err %v; want the step limit after 100", cpu.Steps, cpu.Halted, err)
+	}
+	if _, err := machine([]byte{0x00}, nil).Run(0); err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	if _, err := machine([]byte{0x02}, nil).Run(10); err == nil || errors.Is(err, ErrStepLimit) {
+		t.Errorf("an invalid opcode gave %v", err)
+	}
+	if _, err := machine([]byte{0xAD, 0x00, 0x01}, nil).Run(10); err == nil || errors.Is(err, ErrStepLimit) {
+		t.Errorf("an address past $00FF gave %v", err)
+	}
+	if size, known := OperandBytes(0xD0); size != 1 || !known {
+		t.Errorf("BNE takes %d operand bytes (%v), want 1", size, known)
+	}
+	for opcode := range operandBytes {
+		if _, named := Mnemonics[opcode]; !named {
+			t.Errorf("opcode $%02X has no mnemonic", opcode)
+		}
+	}
+}
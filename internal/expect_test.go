@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Test programs can state what they are for in comments:
+//
+//	/* expect: 0123 */                   what the program prints when run (surrounding spaces trimmed)
+//	/* expect-error: SEM-UNDECLARED */   an error the program must produce
+//	/* expect-warning: GEN-INT-WRAP */   a warning the program must produce
+//
+// An expect belongs to the program it is written in (or the last program if it comes after it).
+// Programs that compile are run on the emulator, so runtime codes like RUN-STEP-LIMIT work too.
+// Output is checked with and without the optimizer; a program that only fits in memory with it
+// is only checked with it. A program expected to hit RUN-STEP-LIMIT only has to start with its output.
+// Diagnostics are those of the build without the optimizer.
+
+var expectRe = regexp.MustCompile(`(?s)/\*\s*expect(-error|-warning)?:(.*?)\*/`)
+
+// instruction budget for annotated programs - infinite loops should fail fast
+const expectStepLimit int = 10000
+
+type expectation struct {
+	program  int
+	severity Severity // the kind of diagnostic expected, "" for output
+	value    string
+}
+
+// which program a byte offset of the source falls in, by counting EOPs outside of comments and strings
+func programAt(src string, offset int) int {
+	var program int = 0
+	var inString bool = false
+	for i := 0; i < offset; i++ {
+		switch {
+		case !inString && strings.HasPrefix(src[i:], "/*"):
+			var end int = strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return program
+			}
+			i += end + 3 // land on the '/' of */
+		case src[i] == '"':
+			inString = !inString
+		case src[i] == '\n':
+			inString = false // strings cannot span lines
+		case !inString && src[i] == '$':
+			program++
+		}
+	}
+	return program
+}
+
+func parseExpectations(src string) []expectation {
+	var expects []expectation
+	for _, match := range expectRe.FindAllStringSubmatchIndex(src, -1) {
+		var severity Severity
+		switch {
+		case match[3] == -1:
+		case src[match[2]:match[3]] == "-error":
+			severity = SeverityError
+		default:
+			severity = SeverityWarning
+		}
+		expects = append(expects, expectation{
+			program:  programAt(src, match[0]),
+			severity: severity,
+			value:    strings.TrimSpace(src[match[4]:match[5]]),
+		})
+	}
+	return expects
+}
+
+func TestExpectations(t *testing.T) {
+	var checked int = 0
+	for _, name := range testCases(t) {
+		src, err := os.ReadFile(filepath.Join(testCaseDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expects []expectation = parseExpectations(string(src))
+		if len(expects) == 0 {
+			continue
+		}
+		checked++
+
+		name := name
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			var result *caseResult = compileCase(t, name)
+			var lastProgram int = len(result.programs) - 1
+
+			// every diagnostic of each program, including running the ones that compiled
+			var diags map[int][]Diagnostic = make(map[int][]Diagnostic)
+			for pNum, pr := range result.programs {
+				diags[pNum] = append(diags[pNum], pr.diags...)
+				if pr.image != nil {
+					_, runDiags := result.compiler.Run(pNum, expectStepLimit)
+					diags[pNum] = append(diags[pNum], runDiags...)
+				}
+			}
+			var builds []*caseResult = []*caseResult{result, compileCaseWith(t, name, true)}
+
+			for _, expect := range expects {
+				var program int = min(expect.program, lastProgram)
+				if expect.severity != "" {
+					if !hasDiagnosticOf(diags[program], expect.value, expect.severity) {
+						t.Errorf("program %d: expected a %s diagnostic (%s), got none", program+1, expect.value, expect.severity)
+					}
+					continue
+				}
+
+				var mayStop bool = hasDiagnosticOf(diags[program], CodeRunStepLimit, SeverityError)
+				var checked bool = false
+				for optimized, build := range builds {
+					if program >= len(build.programs) || build.programs[program].image == nil {
+						continue
+					}
+					checked = true
+					output, runDiags := build.compiler.Run(program, expectStepLimit)
+					if mayStop && hasDiagnosticOf(runDiags, CodeRunStepLimit, SeverityError) {
+						if !strings.HasPrefix(strings.TrimSpace(output), expect.value) {
+							t.Errorf("program %d (optimized %v): expected output starting %q, got %q",
+								program+1, optimized == 1, expect.value, output)
+						}
+					} else if strings.TrimSpace(output) != expect.value {
+						t.Errorf("program %d (optimized %v): expected output %q, got %q", program+1, optimized == 1, expect.value, output)
+					}
+				}
+				if !checked {
+					t.Errorf("program %d: expected output %q but it did not compile", program+1, expect.value)
+				}
+			}
+		})
+	}
+	if checked == 0 {
+		t.Error("no test case has an expect annotation")
+	}
+}
+
+func hasDiagnosticOf(diags []Diagnostic, code string, severity Severity) bool {
+	for _, diag := range diags {
+		if diag.Code == code && diag.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+/* Machine code export.
+Turns compiled memory images into the bytes of a file. Nothing here logs, so the CLI,
+the web server, and anything else can write images without a Compiler's logger. */
+
+type ImageFormat string
+
+const (
+	FormatRaw      ImageFormat = "raw"  // the 256 bytes as they are
+	FormatHex      ImageFormat = "hex"  // space separated hex like GetMachineCode
+	FormatIntelHex ImageFormat = "ihex" // Intel HEX records
+	FormatGo       ImageFormat = "go"   // a Go byte array
+	FormatC        ImageFormat = "c"    // a C byte array
+)
+
+// file extension for each format
+var imageExtensions = map[ImageFormat]string{
+	FormatRaw:      ".bin",
+	FormatHex:      ".txt",
+	FormatIntelHex: ".hex",
+	FormatGo:       ".go",
+	FormatC:        ".c",
+}
+
+func ParseImageFormat(name string) (ImageFormat, error) {
+	var format ImageFormat = ImageFormat(strings.ToLower(name))
+	if _, exists := imageExtensions[format]; !exists {
+		return "", fmt.Errorf("unknown format %q; expected one of raw, hex, ihex, go, c", name)
+	}
+	return format, nil
+}
+
+func (format ImageFormat) Extension() string {
+	return imageExtensions[format]
+}
+
+// An Image is the memory of one program that made it through code generation.
+type Image struct {
+	Program int // indexed from 0 like everything else
+	Memory  [256]byte
+}
+
+// every program that compiled, in order
+func (c *Compiler) Images() []Image {
+	var images []Image
+	for program := range c.memList {
+		if memory := c.GetMemoryImage(program); memory != nil {
+			images = append(images, Image{Program: program, Memory: *memory})
+		}
+	}
+	return images
+}
+
+// EncodeImage is the contents of a file holding image in format
+func EncodeImage(image Image, format ImageFormat) []byte {
+	switch format {
+	case FormatHex:
+		return []byte(hexImage(&image.Memory, false) + "\n")
+	case FormatIntelHex:
+		return intelHex(image.Memory[:])
+	case FormatGo:
+		return []byte(byteArray(image, fmt.Sprintf("var program%d = [256]byte{\n", image.Program+1), "}\n"))
+	case FormatC:
+		return []byte(byteArray(image, fmt.Sprintf("const unsigned char program%d[256] = {\n", image.Program+1), "};\n"))
+	}
+	return append([]byte{}, image.Memory[:]...)
+}
+
+// the memory as hex bytes, with a line break every 8 if eightBreaks
+func hexImage(memory *[256]byte, eightBreaks bool) string {
+	var hexString []string
+	if eightBreaks {
+		hexString = append(hexString, " ")
+	}
+
+	for i, b := range memory {
+		if eightBreaks && i%8 == 0 {
+			hexString = append(hexString, "\n")
+		}
+		hexString = append(hexString, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(hexString, " ")
+}
+
+// 16 byte data records from address 0, then the end of file record
+func intelHex(memory []byte) []byte {
+	var sb strings.Builder
+	for addr := 0; addr < len(memory); addr += 16 {
+		var data []byte = memory[addr:min(addr+16, len(memory))]
+		var record []byte = append([]byte{byte(len(data)), byte(addr >> 8), byte(addr), 0x00}, data...)
+		var sum byte = 0
+		sb.WriteString(":")
+		for _, b := range record {
+			sb.WriteString(fmt.Sprintf("%02X", b))
+			sum += b
+		}
+		sb.WriteString(fmt.Sprintf("%02X\n", -sum)) // the checksum makes the record sum to 0
+	}
+	sb.WriteString(":00000001FF\n")
+	return []byte(sb.String())
+}
+
+// the memory as an array literal, 8 bytes a line
+func byteArray(image Image, open string, close string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("// program %d\n", image.Program+1))
+	sb.WriteString(open)
+	for i, b := range image.Memory {
+		if i%8 == 0 {
+			sb.WriteString("\t")
+		}
+		sb.WriteString(fmt.Sprintf("0x%02X,", b))
+		if i%8 == 7 {
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString(" ")
+		}
+	}
+	sb.WriteString(close)
+	return sb.String()
+}
+
+// ImageFileName is where a program goes when a source's programs are written to separate files:
+// out.bin becomes out_1.bin, out_2.bin, ...
+func ImageFileName(path string, program int, format ImageFormat) string {
+	var ext string = filepath.Ext(path)
+	if ext == "" {
+		ext = format.Extension()
+	}
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, filepath.Ext(path)), program+1, ext)
+}
+
+// WriteArchive writes every image to one zip archive, a file per program
+func WriteArchive(w io.Writer, images []Image, format ImageFormat) error {
+	var archive *zip.Writer = zip.NewWriter(w)
+	for _, image := range images {
+		file, err := archive.Create(fmt.Sprintf("program%d%s", image.Program+1, format.Extension()))
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(EncodeImage(image, format)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
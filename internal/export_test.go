@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func exportImage() Image {
+	var image Image = Image{Program: 1}
+	copy(image.Memory[:], []byte{0xA9, 0x05, 0x00})
+	image.Memory[255] = 0x42
+	return image
+}
+
+func TestEncodeImage(t *testing.T) {
+	var image Image = exportImage()
+
+	if raw := EncodeImage(image, FormatRaw); !bytes.Equal(raw, image.Memory[:]) {
+		t.Errorf("raw image is %d bytes, not the memory", len(raw))
+	}
+	if text := string(EncodeImage(image, FormatHex)); !strings.HasPrefix(text, "A9 05 00 00") || len(text) != 256*3 {
+		t.Errorf("hex image starts %q and is %d characters", text[:11], len(text))
+	}
+	if text := string(EncodeImage(image, FormatGo)); !strings.Contains(text, "var program2 = [256]byte{\n\t0xA9, 0x05,") {
+		t.Errorf("go array is\n%s", text)
+	}
+	if text := string(EncodeImage(image, FormatC)); !strings.Contains(text, "const unsigned char program2[256] = {") ||
+		!strings.HasSuffix(text, "0x42,\n};\n") {
+		t.Errorf("c array is\n%s", text)
+	}
+}
+
+// every record sums to 0 and together they hold the memory
+func TestEncodeIntelHex(t *testing.T) {
+	var image Image = exportImage()
+	var records []string = strings.Fields(string(EncodeImage(image, FormatIntelHex)))
+	if len(records) != 17 || records[16] != ":00000001FF" {
+		t.Fatalf("expected 16 data records and an end record, got %d records ending %q", len(records), records[len(records)-1])
+	}
+	var memory []byte
+	for _, record := range records[:16] {
+		raw, err := hex.DecodeString(strings.TrimPrefix(record, ":"))
+		if err != nil {
+			t.Fatalf("record %q: %v", record, err)
+		}
+		var sum byte = 0
+		for _, b := range raw {
+			sum += b
+		}
+		if sum != 0 {
+			t.Errorf("record %q has a bad checksum", record)
+		}
+		if addr := int(raw[1])<<8 | int(raw[2]); addr != len(memory) {
+			t.Errorf("record %q is at $%04X, expected $%04X", record, addr, len(memory))
+		}
+		memory = append(memory, raw[4:len(raw)-1]...)
+	}
+	if !bytes.Equal(memory, image.Memory[:]) {
+		t.Error("records do not hold the memory")
+	}
+}
+
+func TestImageFileName(t *testing.T) {
+	if name := ImageFileName("out/prog.bin", 0, FormatRaw); name != "out/prog_1.bin" {
+		t.Errorf("got %s", name)
+	}
+	if name := ImageFileName("prog", 2, FormatIntelHex); name != "prog_3.hex" {
+		t.Errorf("got %s", name)
+	}
+	if _, err := ParseImageFormat("elf"); err == nil {
+		t.Error("accepted an unknown format")
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetWebMode(true)
+	c.Compile("{print(1)}${int a a = 2}$", StageCodeGen)
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, c.Images(), FormatRaw); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 2 || archive.File[0].Name != "program1.bin" || archive.File[1].Name != "program2.bin" {
+		t.Fatalf("archive holds %d files", len(archive.File))
+	}
+	if archive.File[1].UncompressedSize64 != 256 {
+		t.Errorf("program2.bin is %d bytes", archive.File[1].UncompressedSize64)
+	}
+}
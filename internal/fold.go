@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/* Constant folding.
+Runs on an analyzed AST right before code generation. Arithmetic, comparisons,
+and logic on literals are evaluated (arithmetic that would wrap past a byte is left
+for the code generator, which warns about it), and if/while statements whose condition folds to a literal lose their dead parts.
+A removed statement leaves an empty <Block> behind so the code generator still
+walks one block per scope in the symbol table. */
+
+// Fold returns a folded copy of a program's AST for code generation.
+// The AST passed in is left as is so it can still be shown.
+func (c *Compiler) Fold(ast *TokenTree, programNum int) (folded *TokenTree, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
+	defer func() {
+		if r := recover(); r != nil {
+			c.CriticalError("constant folder", r)
+			folded = nil
+		}
+		diags = c.diagnosticsSince(firstDiag)
+	}()
+
+	c.curProgram = programNum
+	c.Debug("Folding constants...", "SEMANTIC ANALYZER")
+	folded = &TokenTree{rootNode: c.foldNode(CopyTree(ast.rootNode))}
+	return folded, diags
+}
+
+// folds the children first, so whatever a node ends up as is already as small as it gets
+func (c *Compiler) foldNode(node *Node) *Node {
+	for i, child := range node.Children {
+		node.Children[i] = c.foldNode(child)
+	}
+
+	switch node.Type {
+	case "<Addition>":
+		left, leftOk := intValue(node.Children[0])
+		right, rightOk := intValue(node.Children[1])
+		// sums that wrap are left for the code generator to warn about
+		if leftOk && rightOk && int(left)+int(right) <= 255 {
+			var sum byte = left + right
+			c.Debug(fmt.Sprintf("Folded <Addition> at (%d:%d) to %d", node.Children[0].Token.location.line,
+				node.Children[0].Token.location.startPos, sum), "SEMANTIC ANALYZER")
+			return literalNode(Digit, "DIGIT", strconv.Itoa(int(sum)), node.Children[0].Token.location)
+		}
+
+	case "<Equality>", "<Inequality>":
+		left, leftOk := literalValue(node.Children[0])
+		right, rightOk := literalValue(node.Children[1])
+		if leftOk && rightOk {
+			// analysis made sure both sides have the same type
+			// strings compare by contents, just like the generated code does
+			var result bool = (left == right) == (node.Type == "<Equality>")
+			var loc Location = firstToken(node).location
+			c.Debug(fmt.Sprintf("Folded %s at (%d:%d) to %t", node.Type, loc.line, loc.startPos, result), "SEMANTIC ANALYZER")
+			return boolNode(result, loc)
+		}
+
+	case "<LessThan>", "<GreaterThan>", "<LessOrEqual>", "<GreaterOrEqual>":
+		left, leftOk := intValue(node.Children[0])
+		right, rightOk := intValue(node.Children[1])
+		if leftOk && rightOk {
+			var result bool
+			switch node.Type {
+			case "<LessThan>":
+				result = left < right
+			case "<GreaterThan>":
+				result = left > right
+			case "<LessOrEqual>":
+				result = left <= right
+			default:
+				result = left >= right
+			}
+			var loc Location = node.Children[0].Token.location
+			c.Debug(fmt.Sprintf("Folded %s at (%d:%d) to %t", node.Type, loc.line, loc.startPos, result), "SEMANTIC ANALYZER")
+			return boolNode(result, loc)
+		}
+
+	case "<Subtraction>":
+		left, leftOk := intValue(node.Children[0])
+		right, rightOk := intValue(node.Children[1])
+		// as with sums, ones that wrap are left for the code generator to warn about
+		if leftOk && rightOk && left >= right {
+			var difference byte = left - right
+			c.Debug(fmt.Sprintf("Folded <Subtraction> at (%d:%d) to %d", node.Children[0].Token.location.line,
+				node.Children[0].Token.location.startPos, difference), "SEMANTIC ANALYZER")
+			return literalNode(Digit, "DIGIT", strconv.Itoa(int(difference)), node.Children[0].Token.location)
+		}
+
+	case "<Negation>":
+		if value, ok := boolValue(node.Children[0]); ok {
+			var loc Location = node.Children[0].Token.location
+			c.Debug(fmt.Sprintf("Folded <Negation> at (%d:%d) to %t", loc.line, loc.startPos, !value), "SEMANTIC ANALYZER")
+			return boolNode(!value, loc)
+		}
+
+	case "<Conjunction>", "<Disjunction>":
+		// expressions have no side effects, so either side can decide it
+		var decides bool = node.Type == "<Disjunction>" // true || x, false && x
+		for i, side := range node.Children {
+			value, ok := boolValue(side)
+			if !ok {
+				continue
+			}
+			var loc Location = side.Token.location
+			if value == decides {
+				c.Debug(fmt.Sprintf("Folded %s at (%d:%d) to %t", node.Type, loc.line, loc.startPos, decides), "SEMANTIC ANALYZER")
+				return boolNode(decides, loc)
+			}
+			c.Debug(fmt.Sprintf("Removed the literal side of %s at (%d:%d)", node.Type, loc.line, loc.startPos), "SEMANTIC ANALYZER")
+			return node.Children[1-i] // true && x is just x
+		}
+
+	case "<IfStatement>", "<WhileStatement>":
+		return c.foldBranch(node)
+	}
+	return node
+}
+
+func boolValue(node *Node) (bool, bool) {
+	if node.Type != "Token" || (node.Token.content != "KEYW_TRUE" && node.Token.content != "KEYW_FALSE") {
+		return false, false
+	}
+	return node.Token.content == "KEYW_TRUE", true
+}
+
+func boolNode(value bool, loc Location) *Node {
+	if value {
+		return literalNode(Keyword, "KEYW_TRUE", "true", loc)
+	}
+	return literalNode(Keyword, "KEYW_FALSE", "false", loc)
+}
+
+// if and while over a literal condition
+func (c *Compiler) foldBranch(node *Node) *Node {
+	var condition *Node = node.Children[0]
+	var block *Node = node.Children[1]
+	if condition.Type != "Token" || (condition.Token.content != "KEYW_TRUE" && condition.Token.content != "KEYW_FALSE") {
+		return node
+	}
+	var loc Location = condition.Token.location
+
+	if len(node.Children) == 3 {
+		// an if with an else keeps both blocks for their scopes, but only the one that runs keeps its statements
+		if condition.Token.content == "KEYW_FALSE" {
+			c.Debug(fmt.Sprintf("Removed the if block of <IfStatement> at (%d:%d) as only the else can run", loc.line, loc.startPos), "SEMANTIC ANALYZER")
+			node.Children[1] = CopyNode(block)
+		} else {
+			c.Debug(fmt.Sprintf("Removed the else block of <IfStatement> at (%d:%d) as it can never run", loc.line, loc.startPos), "SEMANTIC ANALYZER")
+			node.Children[2] = CopyNode(node.Children[2])
+		}
+		return node
+	} else if condition.Token.content == "KEYW_FALSE" {
+		c.Debug(fmt.Sprintf("Removed the body of %s at (%d:%d) as it can never run", node.Type, loc.line, loc.startPos), "SEMANTIC ANALYZER")
+		return CopyNode(block) // keeps the scope, drops the statements
+	} else if node.Type == "<IfStatement>" {
+		c.Debug(fmt.Sprintf("Removed the condition of <IfStatement> at (%d:%d) as it always runs", loc.line, loc.startPos), "SEMANTIC ANALYZER")
+		return block
+	}
+
+	c.report(SeverityWarning, StageSemantic, CodeSemInfiniteLoop, loc, tokenWidth(condition.Token),
+		"The condition of this while loop is always true, so it will never end", "Possible infinite loop.")
+	return node
+}
+
+func literalNode(tType TokenType, content string, trueContent string, loc Location) *Node {
+	return NewNode("Token", &Token{tType: tType, location: loc, content: content, trueContent: trueContent})
+}
+
+// value of a digit, wrapped to a byte like the generated code does
+func intValue(node *Node) (byte, bool) {
+	if node.Type != "Token" || node.Token.tType != Digit {
+		return 0, false
+	}
+	value, _ := strconv.Atoi(node.Token.trueContent)
+	return byte(value), true
+}
+
+// something comparable for any literal, false for anything only known at run time
+func literalValue(node *Node) (string, bool) {
+	if value, ok := intValue(node); ok {
+		return strconv.Itoa(int(value)), true
+	} else if node.Type != "Token" {
+		return "", false
+	}
+
+	switch node.Token.content {
+	case "KEYW_TRUE", "KEYW_FALSE":
+		return node.Token.content, true
+	case "STRING":
+		return strconv.Quote(node.Token.trueContent), true
+	}
+	return "", false
+}
+
+// the leftmost token under a node, for positions
+func firstToken(node *Node) *Token {
+	for node.Token == nil {
+		node = node.Children[0]
+	}
+	return node.Token
+}
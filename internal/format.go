@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/* Source formatter.
+Reprints source from its CST in one layout: a statement per line, four spaces of
+indent per block, and a space on each side of binary operators. Comments are not in
+the CST, so the lexer keeps them aside and each one is put back beside the token it
+was written next to - after a token on the same line, or on its own line before the next one.
+Up to one blank line between statements is kept. */
+
+const formatIndent string = "    "
+
+type formatter struct {
+	sb           strings.Builder
+	depth        int    // blocks we are inside
+	lineStart    bool   // nothing written on this line yet
+	prev         *Token // last token written
+	inString     bool
+	afterComment bool // a comment ended the line so far, so the next token needs a space
+	afterOpen    bool // the last line written was a {
+	lastLine     int  // source line of the last thing written, for keeping blank lines
+
+	leading  map[Location][]comment // on lines before a token
+	trailing map[Location][]comment // after a token on its line
+	ending   []comment              // after the last token
+}
+
+// Format reprints every program in the source in the canonical layout.
+// Source that does not lex or parse is not formatted; the error is its first error diagnostic.
+func Format(src string) (string, error) {
+	var c *Compiler = NewCompiler()
+	c.SetWebMode(true) // keep the logs out of the output
+	c.SetVerbose(false)
+
+	programs, _ := c.Lex(src)
+	var csts []*TokenTree
+	var tokens []Token
+	for pNum, programTokens := range programs {
+		if programTokens == nil {
+			return "", formatError(c, pNum)
+		}
+		cst, _ := c.Parse(programTokens, pNum)
+		if cst == nil {
+			return "", formatError(c, pNum)
+		}
+		csts = append(csts, cst)
+		tokens = append(tokens, programTokens...)
+	}
+
+	var f *formatter = &formatter{lineStart: true}
+	f.attach(tokens, c.comments)
+	for _, cst := range csts {
+		f.node(cst.rootNode)
+		f.newline()
+	}
+	for _, cm := range f.ending {
+		f.ownLine(cm)
+	}
+	return f.sb.String(), nil
+}
+
+func formatError(c *Compiler, program int) error {
+	for _, diag := range c.Diagnostics() {
+		if diag.Severity == SeverityError {
+			return errors.New(diag.String())
+		}
+	}
+	return fmt.Errorf("program %d could not be parsed", program+1)
+}
+
+func locationBefore(a Location, b Location) bool {
+	return a.line < b.line || (a.line == b.line && a.startPos < b.startPos)
+}
+
+// decides which token each comment goes with
+func (f *formatter) attach(tokens []Token, comments []comment) {
+	f.leading = make(map[Location][]comment)
+	f.trailing = make(map[Location][]comment)
+
+	var next int = 0 // first token after the comment
+	for _, cm := range comments {
+		for next < len(tokens) && locationBefore(tokens[next].location, cm.location) {
+			next++
+		}
+		if next > 0 && tokens[next-1].location.line == cm.location.line {
+			f.trailing[tokens[next-1].location] = append(f.trailing[tokens[next-1].location], cm)
+		} else if next < len(tokens) {
+			f.leading[tokens[next].location] = append(f.leading[tokens[next].location], cm)
+		} else {
+			f.ending = append(f.ending, cm)
+		}
+	}
+}
+
+func (f *formatter) node(node *Node) {
+	switch node.Type {
+	case "Token":
+		if node.Token.content != "EPS" {
+			f.token(node.Token)
+		}
+
+	case "<Block>":
+		// {, StatementList, }
+		f.token(node.Children[0].Token)
+		f.depth++
+		f.node(node.Children[1])
+		// comments before the } still belong inside the block
+		var closeBrace *Token = node.Children[2].Token
+		f.newline()
+		for _, cm := range f.leading[closeBrace.location] {
+			f.ownLine(cm)
+		}
+		delete(f.leading, closeBrace.location)
+		f.depth--
+		f.newline()
+		f.token(closeBrace)
+
+	case "<Statement>":
+		f.newline()
+		for _, child := range node.Children {
+			f.node(child)
+		}
+
+	default:
+		for _, child := range node.Children {
+			f.node(child)
+		}
+	}
+}
+
+func (f *formatter) token(tok *Token) {
+	if f.lineStart {
+		for _, cm := range f.leading[tok.location] {
+			f.ownLine(cm)
+		}
+		if tok.content != "CLOSE_BRACE" {
+			f.keepBlank(tok.location.line)
+		}
+	} else {
+		for _, cm := range f.leading[tok.location] { // a comment in the middle of a line
+			f.write(" " + cm.text)
+			f.afterComment = true
+		}
+		if f.spaced(tok) {
+			f.write(" ")
+		}
+	}
+
+	f.write(tok.trueContent)
+	if tok.content == "QUOTE" {
+		f.inString = !f.inString
+	}
+	f.prev = tok
+	f.lastLine = tok.location.line
+	f.afterComment = false
+	f.afterOpen = tok.content == "OPEN_BRACE"
+
+	for _, cm := range f.trailing[tok.location] {
+		f.write(" " + cm.text)
+		f.lastLine = cm.endLine
+		f.afterComment = true
+	}
+}
+
+// whether tok is written apart from the token before it on the line
+func (f *formatter) spaced(tok *Token) bool {
+	if f.afterComment {
+		return true
+	} else if f.inString || f.prev == nil {
+		return false
+	}
+	switch {
+	case f.prev.content == "OPEN_PAREN", f.prev.content == "NOT_OP":
+		return false
+	case tok.content == "CLOSE_PAREN", tok.content == "EOP":
+		return false
+	case f.prev.content == "KEYW_PRINT" && tok.content == "OPEN_PAREN":
+		return false
+	}
+	return true
+}
+
+func (f *formatter) ownLine(cm comment) {
+	f.newline()
+	f.keepBlank(cm.location.line)
+	f.write(cm.text)
+	f.lastLine = cm.endLine
+	f.afterOpen = false
+	f.newline()
+}
+
+// one blank line stays if there was at least one in the source, but never right after a {
+func (f *formatter) keepBlank(line int) {
+	if f.lastLine > 0 && line > f.lastLine+1 && !f.afterOpen {
+		f.sb.WriteString("\n")
+	}
+}
+
+func (f *formatter) write(text string) {
+	if f.lineStart {
+		f.sb.WriteString(strings.Repeat(formatIndent, f.depth))
+		f.lineStart = false
+	}
+	f.sb.WriteString(text)
+}
+
+func (f *formatter) newline() {
+	if !f.lineStart {
+		f.sb.WriteString("\n")
+		f.lineStart = true
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatLayout(t *testing.T) {
+	var tests = []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"layout", "/* top */\n{int a a=1+2\n\n\n  if(a==3){print( \"a b\" ) /* why */\n}else{ print(!(a<=2)) }\n/* last */ }$\n/* after */",
+			"/* top */\n{\n    int a\n    a = 1 + 2\n\n    if (a == 3) {\n        print(\"a b\") /* why */\n" +
+				"    } else {\n        print(!(a <= 2))\n    }\n    /* last */\n}$\n/* after */\n"},
+		// the lexer reads a comment after a digit twice
+		{"comment in a token", "{int a a=0/* loop */while(a!=1){a=1+a}}$",
+			"{\n    int a\n    a = 0 /* loop */\n    while (a != 1) {\n        a = 1 + a\n    }\n}$\n"},
+	}
+	for _, test := range tests {
+		got, err := Format(test.src)
+		if err != nil {
+			t.Errorf("%s: Format: %v", test.name, err)
+		} else if got != test.want {
+			t.Errorf("%s: Format gave\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFormatRejectsBadSource(t *testing.T) {
+	if _, err := Format("{ print( }$"); err == nil {
+		t.Error("Format accepted source that does not parse")
+	}
+}
+
+// reformatting changes nothing, and no token or comment is lost along the way
+func TestFormatTestCases(t *testing.T) {
+	var formatted int = 0
+	for _, name := range testCases(t) {
+		src, err := os.ReadFile(filepath.Join(testCaseDir, name))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		once, err := Format(string(src))
+		if err != nil {
+			continue // cases with lex or parse errors
+		}
+		formatted++
+
+		twice, err := Format(once)
+		if err != nil || twice != once {
+			t.Errorf("%s: formatting is not stable (err %v):\n%s\nthen\n%s", filepath.ToSlash(name), err, once, twice)
+			continue
+		}
+		var before, after *Compiler = NewCompiler(), NewCompiler()
+		before.SetWebMode(true)
+		after.SetWebMode(true)
+		if !sameTokens(before, string(src), after, once) {
+			t.Errorf("%s: formatting changed the tokens", filepath.ToSlash(name))
+		}
+		if len(before.comments) != len(after.comments) {
+			t.Errorf("%s: %d comments before formatting, %d after", filepath.ToSlash(name), len(before.comments), len(after.comments))
+		}
+	}
+	if formatted == 0 {
+		t.Error("no test case could be formatted")
+	}
+}
+
+func sameTokens(a *Compiler, aSrc string, b *Compiler, bSrc string) bool {
+	aPrograms, _ := a.Lex(aSrc)
+	bPrograms, _ := b.Lex(bSrc)
+	if len(aPrograms) != len(bPrograms) {
+		return false
+	}
+	for pNum := range aPrograms {
+		if len(aPrograms[pNum]) != len(bPrograms[pNum]) {
+			return false
+		}
+		for i, tok := range aPrograms[pNum] {
+			if tok.content != bPrograms[pNum][i].content || tok.trueContent != bPrograms[pNum][i].trueContent {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/* Golden file regression tests.
+Every file under test_cases/ is compiled and everything the passes produce is
+written as text and compared against testdata/golden/<category>/<case>.golden.
+After an intended change in output regenerate them with:
+	go test ./internal -run TestGolden -update
+and review the diff like any other change. */
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+const testCaseDir string = "../test_cases"
+const goldenDir string = "testdata/golden"
+
+// everything one source file produced, per program
+type caseResult struct {
+	compiler *Compiler
+	programs []programResult
+}
+
+type programResult struct {
+	tokens  []Token
+	cst     string // trees are drawn right away as failed passes free them
+	ast     string
+	symbols *SymbolTableTree
+	image   *[256]byte
+	diags   []Diagnostic
+}
+
+// every test case path relative to test_cases/, sorted
+func testCases(t *testing.T) []string {
+	var cases []string
+	err := filepath.WalkDir(testCaseDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			rel, _ := filepath.Rel(testCaseDir, path)
+			cases = append(cases, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading %s: %v", testCaseDir, err)
+	}
+	sort.Strings(cases)
+	return cases
+}
+
+// runs the source through each pass like Compile does, but keeps every artifact
+func compileCase(t *testing.T, name string) *caseResult {
+	return compileCaseWith(t, name, false)
+}
+
+func compileCaseWith(t *testing.T, name string, optimize bool) *caseResult {
+	src, err := os.ReadFile(filepath.Join(testCaseDir, name))
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetOptimize(optimize)
+	var result *caseResult = &caseResult{compiler: c}
+
+	programs, lexDiags := c.Lex(string(src))
+	for pNum := 0; pNum < c.ProgramCount(); pNum++ {
+		var pr programResult
+		for _, d := range lexDiags {
+			if d.Program == pNum {
+				pr.diags = append(pr.diags, d)
+			}
+		}
+		result.programs = append(result.programs, pr)
+	}
+
+	for pNum, tokens := range programs {
+		var pr *programResult = &result.programs[pNum]
+		pr.tokens = tokens
+		if tokens == nil {
+			continue
+		}
+
+		cst, diags := c.Parse(tokens, pNum)
+		pr.diags = append(pr.diags, diags...)
+		if cst == nil {
+			continue
+		}
+		pr.cst = cst.drawTree()
+
+		ast, diags := c.BuildAST(cst, pNum)
+		pr.diags = append(pr.diags, diags...)
+		if ast == nil {
+			continue
+		}
+		pr.ast = ast.drawTree()
+		pr.symbols, diags = c.Analyze(ast, pNum)
+		pr.diags = append(pr.diags, diags...)
+		if pr.symbols == nil {
+			continue
+		}
+
+		folded, diags := c.Fold(ast, pNum)
+		pr.diags = append(pr.diags, diags...)
+		if folded == nil {
+			continue
+		}
+
+		pr.image, diags = c.Generate(folded, pr.symbols, pNum)
+		pr.diags = append(pr.diags, diags...)
+	}
+	return result
+}
+
+func formatToken(token *Token) string {
+	var content string = token.trueContent
+	if content == " " {
+		content = "space"
+	}
+	return fmt.Sprintf("(%d:%d) %s [ %s ]", token.location.line, token.location.startPos, token.content, content)
+}
+
+func formatDiagnostic(d Diagnostic) string {
+	return fmt.Sprintf("%s %s (%d:%d)-(%d:%d) %s", d.Severity, d.Stage,
+		d.Start.Line, d.Start.Column, d.End.Line, d.End.Column, d)
+}
+
+// the text stored in a golden file
+func (result *caseResult) golden() string {
+	var sb strings.Builder
+	section := func(pNum int, name string, body string) {
+		sb.WriteString(fmt.Sprintf("=== program %d %s ===\n", pNum+1, name))
+		sb.WriteString(strings.TrimRight(body, " \t\n"))
+		sb.WriteString("\n")
+	}
+
+	for pNum, pr := range result.programs {
+		var tokens []string
+		for i := range pr.tokens {
+			tokens = append(tokens, formatToken(&pr.tokens[i]))
+		}
+		section(pNum, "tokens", strings.Join(tokens, "\n"))
+
+		if pr.cst != "" {
+			section(pNum, "cst", pr.cst)
+		}
+		if pr.ast != "" {
+			section(pNum, "ast", pr.ast)
+		}
+		if pr.symbols != nil {
+			section(pNum, "symbols", pr.symbols.ToString())
+		}
+		if pr.image != nil {
+			section(pNum, "ir", result.compiler.GetIR(pNum))
+			section(pNum, "assembly", result.compiler.GetAssembly(pNum))
+			section(pNum, "machine code", result.compiler.GetMachineCode(pNum, true))
+		}
+
+		var diags []string
+		for _, d := range pr.diags {
+			diags = append(diags, formatDiagnostic(d))
+		}
+		section(pNum, "diagnostics", strings.Join(diags, "\n"))
+	}
+	return sb.String()
+}
+
+func TestGolden(t *testing.T) {
+	for _, name := range testCases(t) {
+		name := name
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			var got string = compileCase(t, name).golden()
+			var goldenPath string = filepath.Join(goldenDir, name+".golden")
+
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("missing golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s\n%s", goldenPath, firstDifference(string(want), got))
+			}
+		})
+	}
+}
+
+// the first line that differs, so a failure points somewhere useful
+func firstDifference(want string, got string) string {
+	var wantLines []string = strings.Split(want, "\n")
+	var gotLines []string = strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n\twant: %q\n\tgot:  %q", i+1, w, g)
+		}
+	}
+	return "(no line differs)"
+}
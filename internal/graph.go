@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* Tree graphs.
+The CST and AST drawn as Graphviz DOT or Mermaid flowcharts instead of dashes.
+Non-terminals are labeled with their name and tokens with their content and where they are.
+DOT gets a digraph per program, which dot reads one after another. A Mermaid diagram has to be
+a single document, so it is one flowchart with a subgraph per program. */
+
+type GraphFormat string
+
+const (
+	GraphText    GraphFormat = "text" // the dash indented trees
+	GraphDot     GraphFormat = "dot"
+	GraphMermaid GraphFormat = "mermaid"
+)
+
+func ParseGraphFormat(name string) (GraphFormat, error) {
+	switch format := GraphFormat(strings.ToLower(name)); format {
+	case GraphText, GraphDot, GraphMermaid:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown tree format %q; expected one of text, dot, mermaid", name)
+}
+
+// every program's CST in format
+func (c *Compiler) GetCstAs(format GraphFormat) string {
+	if format == GraphText {
+		return c.GetCst()
+	}
+	return c.drawGraphs(format, "CST", c.CstTree)
+}
+
+// every program's AST in format
+func (c *Compiler) GetAstAs(format GraphFormat) string {
+	if format == GraphText {
+		return c.GetAst()
+	}
+	return c.drawGraphs(format, "AST", c.AstTree)
+}
+
+func (c *Compiler) drawGraphs(format GraphFormat, kind string, tree func(program int) *Node) string {
+	var sb strings.Builder
+	if format == GraphMermaid {
+		sb.WriteString(fmt.Sprintf("---\ntitle: %s\n---\nflowchart TD\n", kind))
+	}
+	for program := 0; program < c.programCount; program++ {
+		var title string = fmt.Sprintf("Program %d %s", program+1, kind)
+		var root *Node = tree(program)
+		if root == nil {
+			var comment string = "//"
+			if format == GraphMermaid {
+				comment = "%%"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s: not generated due to %s error\n", comment, title, c.errorMap[program]))
+		} else if format == GraphDot {
+			drawDot(&sb, root, title)
+		} else {
+			drawMermaid(&sb, root, title, program)
+		}
+		if format == GraphDot {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// a node's name, or a token's content and position
+func graphLabel(node *Node) string {
+	if node.Token == nil {
+		return node.Type
+	}
+	var content string = node.Token.trueContent
+	if content == " " {
+		content = "space"
+	}
+	if node.Token.location.line == 0 { // epsilons and the like were never in the source
+		return fmt.Sprintf("%s [ %s ]", node.Token.content, content)
+	}
+	return fmt.Sprintf("%s [ %s ]\n(%d:%d)", node.Token.content, content,
+		node.Token.location.line, node.Token.location.startPos)
+}
+
+// nodes are numbered in the order they are visited, parent before children
+func walkGraph(node *Node, next *int, visit func(id int, node *Node, parent int), parent int) {
+	var id int = *next
+	*next++
+	visit(id, node, parent)
+	for _, child := range node.Children {
+		walkGraph(child, next, visit, id)
+	}
+}
+
+func drawDot(sb *strings.Builder, root *Node, title string) {
+	var escaper *strings.Replacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	sb.WriteString(fmt.Sprintf("digraph \"%s\" {\n", title))
+	sb.WriteString(fmt.Sprintf("\tlabel=\"%s\";\n\tlabelloc=t;\n\tnode [fontname=\"monospace\"];\n", title))
+	var next int = 0
+	walkGraph(root, &next, func(id int, node *Node, parent int) {
+		var shape string = "ellipse"
+		if node.Token != nil {
+			shape = "box"
+		}
+		sb.WriteString(fmt.Sprintf("\tn%d [label=\"%s\", shape=%s];\n", id, escaper.Replace(graphLabel(node)), shape))
+		if parent >= 0 {
+			sb.WriteString(fmt.Sprintf("\tn%d -> n%d;\n", parent, id))
+		}
+	}, -1)
+	sb.WriteString("}\n")
+}
+
+// one program's subgraph; node ids start with the program so they stay apart from the others'
+func drawMermaid(sb *strings.Builder, root *Node, title string, program int) {
+	// entity codes, as < > and quotes would end up read as markup
+	var escaper *strings.Replacer = strings.NewReplacer(`"`, "#34;", "<", "#60;", ">", "#62;", "\n", "<br>")
+	sb.WriteString(fmt.Sprintf("\tsubgraph p%d [\"%s\"]\n", program+1, title))
+	var next int = 0
+	walkGraph(root, &next, func(id int, node *Node, parent int) {
+		var open, close string = "[\"", "\"]"
+		if node.Token != nil {
+			open, close = "(\"", "\")"
+		}
+		sb.WriteString(fmt.Sprintf("\t\tp%dn%d%s%s%s\n", program+1, id, open, escaper.Replace(graphLabel(node)), close))
+		if parent >= 0 {
+			sb.WriteString(fmt.Sprintf("\t\tp%dn%d --> p%dn%d\n", program+1, parent, program+1, id))
+		}
+	}, -1)
+	sb.WriteString("\tend\n")
+}
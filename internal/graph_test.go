@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTreeGraphs(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetWebMode(true)
+	c.Compile("{print(\"a\")}${print(}${}$", StageCodeGen)
+
+	var dot string = c.GetAstAs(GraphDot)
+	for _, want := range []string{
+		"digraph \"Program 1 AST\" {",
+		"\tn1 [label=\"<Block>\", shape=ellipse];",
+		"\tn3 [label=\"STRING [ a ]\\n(1:8)\", shape=box];",
+		"\tn2 -> n3;",
+		"// Program 2 AST: not generated due to parser error",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT AST is missing %q:\n%s", want, dot)
+		}
+	}
+
+	var mermaid string = c.GetCstAs(GraphMermaid)
+	for _, want := range []string{
+		"---\ntitle: CST\n---\nflowchart TD\n\tsubgraph p1 [\"Program 1 CST\"]\n",
+		"\t\tp1n0[\"#60;Program#62;\"]",
+		"(\"QUOTE [ #34; ]<br>(1:8)\")",
+		"\tend\n%% Program 2 CST: not generated due to parser error\n\tsubgraph p3",
+		"\t\tp3n0 --> p3n1\n",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid CST is missing %q:\n%s", want, mermaid)
+		}
+	}
+	// a diagram is one document, whatever the number of programs
+	if strings.Count(mermaid, "flowchart") != 1 {
+		t.Errorf("Mermaid CST has more than one flowchart:\n%s", mermaid)
+	}
+
+	if _, err := ParseGraphFormat("svg"); err == nil {
+		t.Error("accepted an unknown tree format")
+	}
+}
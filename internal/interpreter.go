@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"gopiler/internal/emulator"
+	"strconv"
+	"strings"
+)
+
+/* Reference interpreter.
+Runs an analyzed AST directly, with the semantics the generated code is supposed to have:
+ints are bytes that wrap, variables start at 0, false, or the empty string like
+generateVarDecl makes them, booleans print as 0 or 1, and strings compare by contents.
+A name means the closest declaration already made, as it does in analysis.
+It knows nothing about memory, so it is what the emulator's output is checked against. */
+
+// a value of any of the three types; booleans are 0 or 1 like in memory
+type interpValue struct {
+	dataType string
+	num      byte
+	str      string
+}
+
+func (value interpValue) String() string {
+	if value.dataType == "string" {
+		return value.str
+	}
+	return strconv.Itoa(int(value.num))
+}
+
+type interpreter struct {
+	scopes   []map[string]*interpValue // innermost last
+	output   strings.Builder
+	steps    int
+	maxSteps int
+}
+
+// stops a runaway program from inside the tree walk
+type interpStepLimit struct{}
+
+// Interpret runs the AST of a program that passed semantic analysis and returns what it printed.
+// Like the emulator, it gives up with emulator.ErrStepLimit after maxSteps statements (no limit if maxSteps <= 0).
+func Interpret(root *Node, maxSteps int) (output string, err error) {
+	var interp *interpreter = &interpreter{maxSteps: maxSteps}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, stopped := r.(interpStepLimit); !stopped {
+				panic(r)
+			}
+			output, err = interp.output.String(), emulator.ErrStepLimit
+		}
+	}()
+	interp.statement(root)
+	return interp.output.String(), nil
+}
+
+func (interp *interpreter) statement(node *Node) {
+	interp.steps++
+	if interp.maxSteps > 0 && interp.steps > interp.maxSteps {
+		panic(interpStepLimit{})
+	}
+
+	switch node.Type {
+	case "<Block>":
+		interp.scopes = append(interp.scopes, map[string]*interpValue{})
+		for _, child := range node.Children {
+			interp.statement(child)
+		}
+		interp.scopes = interp.scopes[:len(interp.scopes)-1]
+
+	case "<VarDecl>":
+		// the same defaults generateVarDecl stores
+		var dataType string = "int"
+		switch node.Children[0].Token.content {
+		case "B_TYPE":
+			dataType = "boolean"
+		case "S_TYPE":
+			dataType = "string"
+		}
+		interp.scopes[len(interp.scopes)-1][node.Children[1].Token.trueContent] = &interpValue{dataType: dataType}
+
+	case "<AssignmentStatement>":
+		*interp.variable(node.Children[0].Token.trueContent) = interp.expr(node.Children[1])
+
+	case "<PrintStatement>":
+		interp.output.WriteString(interp.expr(node.Children[0]).String())
+
+	case "<IfStatement>":
+		if interp.expr(node.Children[0]).num == 1 {
+			interp.statement(node.Children[1])
+		} else if len(node.Children) == 3 {
+			interp.statement(node.Children[2])
+		}
+
+	case "<WhileStatement>":
+		for interp.expr(node.Children[0]).num == 1 {
+			interp.statement(node.Children[1])
+		}
+
+	default:
+		for _, child := range node.Children {
+			interp.statement(child)
+		}
+	}
+}
+
+// the innermost variable with the name; analysis made sure there is one
+func (interp *interpreter) variable(name string) *interpValue {
+	for i := len(interp.scopes) - 1; i >= 0; i-- {
+		if value, exists := interp.scopes[i][name]; exists {
+			return value
+		}
+	}
+	panic("interpreter: undeclared variable " + name)
+}
+
+func boolean(value bool) interpValue {
+	if value {
+		return interpValue{dataType: "boolean", num: 1}
+	}
+	return interpValue{dataType: "boolean", num: 0}
+}
+
+func (interp *interpreter) expr(node *Node) interpValue {
+	if node.Type == "Token" {
+		switch {
+		case node.Token.tType == Digit:
+			return interpValue{dataType: "int", num: strIntToByte(node.Token.trueContent)}
+		case node.Token.tType == Identifier:
+			return *interp.variable(node.Token.trueContent)
+		case node.Token.content == "STRING":
+			return interpValue{dataType: "string", str: node.Token.trueContent}
+		}
+		return boolean(node.Token.content == "KEYW_TRUE")
+	}
+
+	var left interpValue = interp.expr(node.Children[0])
+	if node.Type == "<Negation>" {
+		return boolean(left.num == 0)
+	}
+	var right interpValue = interp.expr(node.Children[1])
+
+	switch node.Type {
+	case "<Addition>":
+		return interpValue{dataType: "int", num: left.num + right.num} // bytes wrap on their own
+	case "<Subtraction>":
+		return interpValue{dataType: "int", num: left.num - right.num}
+	case "<Equality>":
+		return boolean(left == right)
+	case "<Inequality>":
+		return boolean(left != right)
+	case "<LessThan>":
+		return boolean(left.num < right.num)
+	case "<GreaterThan>":
+		return boolean(left.num > right.num)
+	case "<LessOrEqual>":
+		return boolean(left.num <= right.num)
+	case "<GreaterOrEqual>":
+		return boolean(left.num >= right.num)
+	case "<Conjunction>":
+		return boolean(left.num == 1 && right.num == 1)
+	case "<Disjunction>":
+		return boolean(left.num == 1 || right.num == 1)
+	}
+	panic("interpreter: unknown expression " + node.Type)
+}
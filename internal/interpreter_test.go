@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"gopiler/internal/emulator"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpret(t *testing.T) {
+	var tests = []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"defaults", `{int a boolean b string s print(a) print(b) print(s) print("x")}$`, "00x"},
+		{"wrap", `{int a a = 250 + 9 print(a) a = 3 - 5 print(a)}$`, "3254"},
+		{"strings by contents", `{string s s = "ab" print((s == "ab")) print((s != "ab"))}$`, "10"},
+		{"scopes", `{int a a = 1 {int a a = 2 print(a)} print(a) while (a != 3) {int b a = 1 + a print(b)}}$`, "2100"},
+		{"else", `{if (1 >= 2) {print(1)} else {print(2)} if (!(1 < 2) || true) {print(3)}}$`, "23"},
+	}
+	for _, test := range tests {
+		var c *Compiler = NewCompiler()
+		c.SetVerbose(false)
+		c.SetWebMode(true)
+		c.Compile(test.src, StageSemantic)
+		if c.SymbolTree(0) == nil {
+			t.Errorf("%s: did not pass analysis: %v", test.name, c.Diagnostics())
+			continue
+		}
+		if got, err := Interpret(c.AstTree(0), 0); err != nil || got != test.want {
+			t.Errorf("%s: printed %q (%v), want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestInterpretStepLimit(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	c.Compile(`{int a while true {a = 1 + a}}$`, StageSemantic)
+	if _, err := Interpret(c.AstTree(0), 1000); !errors.Is(err, emulator.ErrStepLimit) {
+		t.Errorf("an infinite loop gave %v, want the step limit", err)
+	}
+}
+
+// every test program that compiles prints the same thing on the emulator as when interpreted,
+// with and without the optimizer
+// (except ones the code generator warns use a redeclaration early, which it knowingly gets wrong,
+// and ones that never finish in either)
+func TestInterpreterMatchesEmulator(t *testing.T) {
+	// the emulator counts instructions and the interpreter statements, so neither limit says when the
+	// other would stop; this many is far more than either needs for what the other finished in expectStepLimit
+	const longStepLimit int = 100 * expectStepLimit
+
+	var compared int = 0
+	for _, name := range testCases(t) {
+		for _, optimize := range []bool{false, true} {
+			var result *caseResult = compileCaseWith(t, name, optimize)
+			for pNum, pr := range result.programs {
+				if pr.image == nil || hasDiagnosticOf(pr.diags, CodeGenEarlyRedeclUse, SeverityWarning) {
+					continue
+				}
+				var program string = fmt.Sprintf("%s program %d (optimize %v)", filepath.ToSlash(name), pNum+1, optimize)
+				var ast *Node = result.compiler.AstTree(pNum)
+
+				want, emulatorErr := emulator.New(*pr.image).Run(expectStepLimit)
+				if errors.Is(emulatorErr, emulator.ErrStepLimit) {
+					if _, err := Interpret(ast, expectStepLimit); errors.Is(err, emulator.ErrStepLimit) {
+						continue // it loops, as far as either can tell
+					}
+					// the interpreter finished it, so the emulator has to given the time
+					want, emulatorErr = emulator.New(*pr.image).Run(longStepLimit)
+				}
+				got, err := Interpret(ast, longStepLimit)
+				if emulatorErr != nil {
+					t.Errorf("%s: emulator: %v (the interpreter printed %q, error %v)", program, emulatorErr, got, err)
+				} else if err != nil {
+					t.Errorf("%s: interpreter: %v", program, err)
+				} else if got != want {
+					t.Errorf("%s: emulator printed %q, interpreter %q", program, want, got)
+				}
+				compared++
+			}
+		}
+	}
+	if compared == 0 {
+		t.Error("no programs were compared")
+	}
+}
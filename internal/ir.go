@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"fmt"
+	"gopiler/internal/emulator"
+	"strings"
+)
+
+/* Intermediate representation.
+The code generator walks the AST and emits a linear list of 6502 instructions,
+but nothing in it has an address yet: branches go to labels and variables live
+in slots. Lowering (lowering.go) lays the list out in memory, which is the only
+place bytes and assembly text are produced. Anything that wants to rewrite or
+print the code, like the peephole optimizer, works on this list instead. */
+
+type operandKind int
+
+const (
+	operandNone      operandKind = iota
+	operandImmediate             // #$XX
+	operandAbsolute              // a fixed address, only the $00FF scratch byte
+	operandSlot                  // a variable or temporary, addressed once statics are laid out
+	operandLabel                 // a branch target
+	operandSelf                  // the instruction's own address
+	operandCodeByte              // a byte of the code itself, value bytes past a label
+	operandDistance              // #$XX, how far a branch at label from must go to reach label
+)
+
+type irOperand struct {
+	kind  operandKind
+	value byte // immediate or absolute address
+	slot  *slot
+	label *label
+	from  *label // only for distances
+}
+
+// One instruction, or a label definition (which takes no space) when label is set
+type irInstr struct {
+	opcode  byte
+	operand irOperand
+	label   *label
+}
+
+// a named place in the code for branches to go
+type label struct {
+	id   int
+	addr int // set when lowered
+}
+
+// a byte of static memory after the code
+type slot struct {
+	id     int
+	symbol *SymbolEntry // nil for temporaries
+	scope  string       // where the symbol was declared
+	addr   byte         // set when lowered
+}
+
+func noOperand() irOperand {
+	return irOperand{kind: operandNone}
+}
+
+func immediate(value byte) irOperand {
+	return irOperand{kind: operandImmediate, value: value}
+}
+
+// the reserved byte at $00FF, always written right before it is read
+func scratch() irOperand {
+	return irOperand{kind: operandAbsolute, value: 0xFF}
+}
+
+func slotOperand(s *slot) irOperand {
+	return irOperand{kind: operandSlot, slot: s}
+}
+
+func labelOperand(l *label) irOperand {
+	return irOperand{kind: operandLabel, label: l}
+}
+
+func selfOperand() irOperand {
+	return irOperand{kind: operandSelf}
+}
+
+// for code that rewrites the operands of other instructions
+func codeByte(l *label, offset byte) irOperand {
+	return irOperand{kind: operandCodeByte, value: offset, label: l}
+}
+
+// the offset a BNE placed at from needs to land on to
+func distance(from *label, to *label) irOperand {
+	return irOperand{kind: operandDistance, label: to, from: from}
+}
+
+// every label the operand needs an address for
+func (op irOperand) labels() []*label {
+	switch op.kind {
+	case operandLabel:
+		return []*label{op.label}
+	case operandCodeByte:
+		return []*label{op.label}
+	case operandDistance:
+		return []*label{op.from, op.label}
+	}
+	return nil
+}
+
+func (c *Compiler) emit(opcode byte, operand irOperand) {
+	c.ir = append(c.ir, &irInstr{opcode: opcode, operand: operand})
+}
+
+func (c *Compiler) newLabel() *label {
+	c.labelCount++
+	return &label{id: c.labelCount - 1}
+}
+
+// the next instruction emitted is where the label points
+func (c *Compiler) placeLabel(l *label) {
+	c.ir = append(c.ir, &irInstr{label: l})
+}
+
+func (c *Compiler) newSlot(symbol *SymbolEntry, scope string) *slot {
+	var s *slot = &slot{id: len(c.slots), symbol: symbol, scope: scope}
+	c.slots = append(c.slots, s)
+	return s
+}
+
+// bytes the instruction takes once lowered
+func (ins *irInstr) size() int {
+	if ins.label != nil {
+		return 0
+	}
+	operandBytes, _ := emulator.OperandBytes(ins.opcode)
+	return 1 + operandBytes
+}
+
+func (s *slot) String() string {
+	if s.symbol == nil {
+		return fmt.Sprintf("t%d", s.id)
+	}
+	return fmt.Sprintf("%s@%s", s.symbol.name, s.scope)
+}
+
+func (l *label) String() string {
+	return fmt.Sprintf("L%d", l.id)
+}
+
+// readable form with labels and slots by name
+func (ins *irInstr) String() string {
+	if ins.label != nil {
+		return ins.label.String() + ":"
+	}
+	var mnemonic string = emulator.Mnemonics[ins.opcode]
+	switch ins.operand.kind {
+	case operandImmediate:
+		return fmt.Sprintf("%s #$%02X", mnemonic, ins.operand.value)
+	case operandAbsolute:
+		return fmt.Sprintf("%s $00%02X", mnemonic, ins.operand.value)
+	case operandSlot:
+		return fmt.Sprintf("%s %s", mnemonic, ins.operand.slot)
+	case operandLabel:
+		return fmt.Sprintf("%s %s", mnemonic, ins.operand.label)
+	case operandSelf:
+		return fmt.Sprintf("%s *", mnemonic)
+	case operandCodeByte:
+		return fmt.Sprintf("%s %s+%d", mnemonic, ins.operand.label, ins.operand.value)
+	case operandDistance:
+		return fmt.Sprintf("%s #(%s-%s)", mnemonic, ins.operand.label, ins.operand.from)
+	}
+	return mnemonic
+}
+
+func irListing(code []*irInstr) string {
+	var sb strings.Builder
+	for _, ins := range code {
+		if ins.label == nil {
+			sb.WriteString("\t")
+		}
+		sb.WriteString(ins.String() + "\n")
+	}
+	return sb.String()
+}
+
+// the IR a program was lowered from, after any optimization
+func (c *Compiler) GetIR(program int) string {
+	if program < 0 || program > len(c.irList)-1 {
+		return "Invalid program number"
+	} else if c.hadError(program) || c.irList[program] == nil {
+		return fmt.Sprintf("No IR generated due to %s error", c.errorMap[program])
+	}
+	return "IR:\n" + irListing(c.irList[program])
+}
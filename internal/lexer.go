@@ -7,14 +7,39 @@ import (
 	"unicode"
 )
 
-var tokenRe = regexp.MustCompile(`^(boolean|string|print|while|false|true|int|if|[a-z]|\d)\S*$`)
+var tokenRe = regexp.MustCompile(`^(boolean|string|print|while|false|true|else|int|if|[a-z]|\d+)\S*$`)
+
+// lexer state - the lexer itself works on locals, this is what outlives it
+type lexerState struct {
+	programCount int           // how many programs the source was split into
+	programSpans [][2]Position // first and last token of each program
+	comments     []comment     // every comment in the source, in order, for tools that reprint it
+	tokenList    [][]Token     // each program's tokens, kept even if it failed lexing
+}
+
+// a comment the lexer skipped over, delimiters and all
+type comment struct {
+	text     string
+	location Location // where the /* is
+	endLine  int      // line of the */
+}
+
+// where a program sits in the source: start of its first token to the end of its last (exclusive)
+func (c *Compiler) ProgramSpan(program int) (start Position, end Position, ok bool) {
+	if program < 0 || program >= len(c.programSpans) {
+		return Position{}, Position{}, false
+	}
+	return c.programSpans[program][0], c.programSpans[program][1], true
+}
 
-func nextProgram(programNum *int, tokenStream *[][]Token, errors *int, warns *int, alreadyFailed *bool) {
+func (c *Compiler) nextProgram(programNum *int, tokenStream *[][]Token, errors *int, warns *int, alreadyFailed *bool) {
 	*programNum++ // deref to update it
+	c.programCount = *programNum + 1
 	// add another array for the next program's tokens
 	*tokenStream = append(*tokenStream, []Token{})
 	// +1 for human indexing starting at 1
-	Info(fmt.Sprintf("Lexing program %d", *programNum+1), "GOPILER", true)
+	c.Info(fmt.Sprintf("Lexing program %d", *programNum+1), "GOPILER", true)
+	c.curProgram = *programNum
 
 	// reset
 	*errors = 0
@@ -63,22 +88,53 @@ func evaluateTokenBuffer(tokenBuffer []rune) string {
 	return "NOMATCH"
 }
 
-func passFailProgram(programNum int, errorCount int, warningCount int, tokenStream [][]Token, alreadyFailed *bool) {
+func (c *Compiler) passFailProgram(programNum int, errorCount int, warningCount int, tokenStream [][]Token, alreadyFailed *bool) {
+	for len(c.tokenList) <= programNum {
+		c.tokenList = append(c.tokenList, nil)
+	}
+	c.tokenList[programNum] = append([]Token{}, tokenStream[programNum]...)
+
+	if tokens := tokenStream[programNum]; len(tokens) > 0 {
+		var first, last *Token = &tokens[0], &tokens[len(tokens)-1]
+		for len(c.programSpans) <= programNum {
+			c.programSpans = append(c.programSpans, [2]Position{})
+		}
+		c.programSpans[programNum] = [2]Position{
+			{Line: first.location.line, Column: first.location.startPos},
+			{Line: last.location.line, Column: last.location.startPos + tokenWidth(last)},
+		}
+	}
 	if errorCount == 0 {
-		Pass(fmt.Sprintf("Lexer processed program %d with %d warnings(s), producing %d tokens.",
+		c.Pass(fmt.Sprintf("Lexer processed program %d with %d warnings(s), producing %d tokens.",
 			programNum+1, warningCount, len(tokenStream[programNum])), "LEXER")
-		Parse(tokenStream[programNum], programNum)
 	} else {
-		CreateFailedProgramVars(programNum, "lexer")
-		Fail(fmt.Sprintf("Lexer failed with %d error(s) and %d warning(s).", errorCount, warningCount), "LEXER")
-		Info(fmt.Sprintf("Compilation of program %d aborted due to lexer error.", programNum+1), "GOPILER", false)
-		tokenStream[programNum] = []Token{} // release memory as tokens will never be used
+		c.CreateFailedProgramVars(programNum, "lexer")
+		c.Fail(fmt.Sprintf("Lexer failed with %d error(s) and %d warning(s).", errorCount, warningCount), "LEXER")
+		c.Info(fmt.Sprintf("Compilation of program %d aborted due to lexer error.", programNum+1), "GOPILER", false)
+		tokenStream[programNum] = nil // release memory as tokens will never be used
 		*alreadyFailed = true
-		errorMap[programNum] = "lexer"
+		c.errorMap[programNum] = "lexer"
 	}
 }
 
-// for != and ==
+// a comment between the halves of a 2 rune symbol never goes through the comment flag
+func (c *Compiler) keepSplitComment(codeRunes []rune, firstPos int, secondPos int, loc Location) {
+	if secondPos > firstPos+1 {
+		c.keepComment(comment{text: string(codeRunes[firstPos+1 : secondPos]), location: loc, endLine: loc.line})
+	}
+}
+
+// a comment in the middle of a token is read again once the token is taken off the buffer
+func (c *Compiler) keepComment(cm comment) {
+	for _, kept := range c.comments {
+		if kept.location == cm.location {
+			return
+		}
+	}
+	c.comments = append(c.comments, cm)
+}
+
+// for !=, ==, <= and >=
 func nextRune(target rune, codeRunes []rune, currentPos int) int {
 	// -1 if next rune is not target
 	// position of target rune after comment if that ever happens
@@ -99,11 +155,11 @@ func nextRune(target rune, codeRunes []rune, currentPos int) int {
 	return -1
 }
 
-func tokenize(capture string, line int, pos int, quoteFlag bool) Token {
+func (c *Compiler) tokenize(capture string, line int, pos int, quoteFlag bool) Token {
 	var tokenType TokenType
 	var formalName string
 	switch capture {
-	case "print", "while", "false", "true", "if":
+	case "print", "while", "false", "true", "if", "else":
 		tokenType = Keyword
 		formalName = "KEYW_" + strings.ToUpper(capture)
 
@@ -119,6 +175,26 @@ func tokenize(capture string, line int, pos int, quoteFlag bool) Token {
 		tokenType = Symbol
 		formalName = "N-EQUAL_OP"
 
+	case "<=":
+		tokenType = Symbol
+		formalName = "LESS_EQ_OP"
+
+	case ">=":
+		tokenType = Symbol
+		formalName = "GREATER_EQ_OP"
+
+	case "&&":
+		tokenType = Symbol
+		formalName = "AND_OP"
+
+	case "||":
+		tokenType = Symbol
+		formalName = "OR_OP"
+
+	case "!":
+		tokenType = Symbol
+		formalName = "NOT_OP"
+
 	case " ":
 		tokenType = Character
 		formalName = "CHAR"
@@ -144,9 +220,9 @@ func tokenize(capture string, line int, pos int, quoteFlag bool) Token {
 
 	// no ternary '?' in go :()
 	if capture == " " {
-		Debug(fmt.Sprintf("%s [ (space) ] found at (%d:%d)", formalName, line, pos), "LEXER")
+		c.Debug(fmt.Sprintf("%s [ (space) ] found at (%d:%d)", formalName, line, pos), "LEXER")
 	} else {
-		Debug(fmt.Sprintf("%s [ %s ] found at (%d:%d)", formalName, capture, line, pos), "LEXER")
+		c.Debug(fmt.Sprintf("%s [ %s ] found at (%d:%d)", formalName, capture, line, pos), "LEXER")
 	}
 
 	token := Token{
@@ -161,11 +237,16 @@ func tokenize(capture string, line int, pos int, quoteFlag bool) Token {
 	return token
 }
 
-func Lex(filedata string) {
+// Lex splits the source into programs and tokenizes each one.
+// A program that failed lexing has nil tokens.
+func (c *Compiler) Lex(filedata string) (tokenStream [][]Token, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
 	defer func() { // so that any errors do not explode the compiler
 		if r := recover(); r != nil {
-			CriticalError("lexer", r)
+			c.CriticalError("lexer", r)
+			tokenStream = nil
 		}
+		diags = c.diagnosticsSince(firstDiag)
 	}()
 
 	// convert string to array of runes
@@ -175,8 +256,7 @@ func Lex(filedata string) {
 
 	var programNum int = -1 // since we increment it to 0 on start
 	// careful: indexed from 0 but programs from 1
-	// this is NOT an array: it is a 'slice' (dynamically allocated)
-	var tokenStream [][]Token
+	// tokenStream is NOT an array: it is a 'slice' (dynamically allocated)
 	var currentPos int = 0
 	var lastPos int = 0
 	var line int = 1  // start at 1 as its a value for user only
@@ -191,13 +271,14 @@ func Lex(filedata string) {
 	var commentFlag bool = false
 	var evaluateBuffer = false
 	var lastCommentStart int = 0
+	var commentLoc Location // where lastCommentStart is, for keeping the comment
 	var inTokenCommentPos int = 0
 	// if comment after EOP but before EOF is unterminated throw err for last program
 	var untermEndComment bool = false
 	var alreadyErrUntermComment bool = false // so we don't do it twice
 	var alreadyFailed bool = false           // when we clear tokens on fail its not empty file issue
 
-	nextProgram(&programNum, &tokenStream, &errorCount, &warningCount, &alreadyFailed)
+	c.nextProgram(&programNum, &tokenStream, &errorCount, &warningCount, &alreadyFailed)
 
 	// extract tokens
 	for lastPos < len(codeRunes) {
@@ -206,27 +287,29 @@ func Lex(filedata string) {
 		if quoteFlag && liveRune != '"' {
 			var currentCol = lastPos - deadPos + 1
 			if !(unicode.IsLower(liveRune) || liveRune == ' ') {
+				var runeLoc Location = Location{line: line, startPos: currentCol}
+				var foundMsg string = fmt.Sprintf("Invalid character [ %c ] found in quote", liveRune)
 				if liveRune == '\n' {
-					Error(fmt.Sprintf("Invalid character [ \\n ] found in quote at (%d:%d); "+
-						"Multiline strings are not permitted.", line, currentCol), "LEXER")
+					c.report(SeverityError, StageLexer, CodeLexMultilineString, runeLoc, 1,
+						"Invalid character [ \\n ] found in quote", "Multiline strings are not permitted.")
 					lastPos-- // bc newline function and this block both add to it
 					handleNewLine(&line, &lastPos, &deadPos)
 				} else if liveRune == '$' {
-					Error(fmt.Sprintf("Invalid character [ %c ] found in quote at (%d:%d); "+
-						"Perhaps your string is unterminated.", liveRune, line, currentCol), "LEXER")
+					c.report(SeverityError, StageLexer, CodeLexInvalidStringChar, runeLoc, 1,
+						foundMsg, "Perhaps your string is unterminated.")
 				} else if unicode.IsUpper(liveRune) {
-					Error(fmt.Sprintf("Invalid character [ %c ] found in quote at (%d:%d); "+
-						"Hint: Capital letters are not permitted in strings.", liveRune, line, currentCol), "LEXER")
+					c.report(SeverityError, StageLexer, CodeLexInvalidStringChar, runeLoc, 1,
+						foundMsg, "Capital letters are not permitted in strings.")
 				} else if unicode.IsDigit(liveRune) {
-					Error(fmt.Sprintf("Invalid character [ %c ] found in quote at (%d:%d); "+
-						"Hint: Digits are not permitted in strings.", liveRune, line, currentCol), "LEXER")
+					c.report(SeverityError, StageLexer, CodeLexInvalidStringChar, runeLoc, 1,
+						foundMsg, "Digits are not permitted in strings.")
 				} else {
-					Error(fmt.Sprintf("Invalid character [ %c ] found in quote at (%d:%d)", liveRune, line, currentCol), "LEXER")
+					c.report(SeverityError, StageLexer, CodeLexInvalidStringChar, runeLoc, 1, foundMsg, "")
 				}
 				errorCount++
 
 			} else { // valid quote chars get their own tokens
-				newToken = tokenize(string(liveRune), line, currentCol, quoteFlag)
+				newToken = c.tokenize(string(liveRune), line, currentCol, quoteFlag)
 				tokenStream[programNum] = append(tokenStream[programNum], newToken)
 			}
 			lastPos++ // we added a char
@@ -236,6 +319,7 @@ func Lex(filedata string) {
 			// lookahead for the / after *
 			if liveRune == '*' && currentPos < len(codeRunes)-1 && codeRunes[currentPos+1] == '/' {
 				commentFlag = false
+				c.keepComment(comment{text: string(codeRunes[lastCommentStart : currentPos+2]), location: commentLoc, endLine: line})
 				if len(tokenBuffer) == 0 { // can't update last pos if stuff exists pre-comment
 					lastPos += 2 // close comment is 2 characters
 					currentPos = lastPos - 1
@@ -257,18 +341,19 @@ func Lex(filedata string) {
 
 		} else if isSymbol(liveRune) {
 			if len(tokenBuffer) == 0 { // found a symbol to tokenize directly
-				// check for == with lookahead
+				// check for ==, <= and >= with lookahead
 				// we do this crazy logic to ensure =/*COMMENT*/= registers as ==
 				var secondEqPos int = nextRune('=', codeRunes, currentPos)
-				if liveRune == '=' && secondEqPos != -1 {
-					newToken = tokenize(string(liveRune)+string(codeRunes[secondEqPos]), line, lastPos-deadPos+1, quoteFlag)
+				if (liveRune == '=' || liveRune == '<' || liveRune == '>') && secondEqPos != -1 {
+					newToken = c.tokenize(string(liveRune)+string(codeRunes[secondEqPos]), line, lastPos-deadPos+1, quoteFlag)
+					c.keepSplitComment(codeRunes, currentPos, secondEqPos, Location{line: line, startPos: currentPos - deadPos + 2})
 					lastPos = secondEqPos + 1 // 2 rune symbol
 					currentPos = lastPos - 1  // incremented at end of loop
 					tokenStream[programNum] = append(tokenStream[programNum], newToken)
 
 				} else {
 					// tokenize single symbol
-					newToken = tokenize(string(liveRune), line, lastPos-deadPos+1, quoteFlag)
+					newToken = c.tokenize(string(liveRune), line, lastPos-deadPos+1, quoteFlag)
 					lastPos++
 					currentPos = lastPos - 1
 					tokenStream[programNum] = append(tokenStream[programNum], newToken)
@@ -276,16 +361,17 @@ func Lex(filedata string) {
 					// special cases
 					if liveRune == '$' { // EOP
 						if nextProgramExists(codeRunes, currentPos, &untermEndComment) {
-							passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
-							nextProgram(&programNum, &tokenStream, &errorCount, &warningCount, &alreadyFailed)
+							c.passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
+							c.nextProgram(&programNum, &tokenStream, &errorCount, &warningCount, &alreadyFailed)
 						} else if untermEndComment {
-							Error("Unterminated comment after EOP.", "LEXER")
+							c.report(SeverityError, StageLexer, CodeLexUntermComment, Location{}, 0,
+								"Unterminated comment after EOP.", "")
 							errorCount++
 							untermEndComment = false
 							alreadyErrUntermComment = true
-							passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
+							c.passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
 						} else {
-							passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
+							c.passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
 						}
 
 					} else if liveRune == '"' {
@@ -313,7 +399,8 @@ func Lex(filedata string) {
 			var followingEqPos int = nextRune('=', codeRunes, currentPos)
 			if liveRune == '!' && followingEqPos != -1 {
 				if len(tokenBuffer) == 0 {
-					newToken = tokenize(string(liveRune)+string(codeRunes[followingEqPos]), line, lastPos-deadPos+1, quoteFlag)
+					newToken = c.tokenize(string(liveRune)+string(codeRunes[followingEqPos]), line, lastPos-deadPos+1, quoteFlag)
+					c.keepSplitComment(codeRunes, currentPos, followingEqPos, Location{line: line, startPos: currentPos - deadPos + 2})
 					lastPos = followingEqPos + 1 // 2 rune symbol
 					currentPos = lastPos - 1
 					tokenStream[programNum] = append(tokenStream[programNum], newToken)
@@ -323,10 +410,29 @@ func Lex(filedata string) {
 					tokenBuffer = append(tokenBuffer, liveRune)
 				}
 
+				// && and || (same crazy logic as ==), and ! on its own
+			} else if liveRune == '!' || ((liveRune == '&' || liveRune == '|') && nextRune(liveRune, codeRunes, currentPos) != -1) {
+				if len(tokenBuffer) > 0 { // use it as a delimiter
+					evaluateBuffer = true
+				} else if liveRune == '!' {
+					newToken = c.tokenize(string(liveRune), line, lastPos-deadPos+1, quoteFlag)
+					lastPos++
+					currentPos = lastPos - 1
+					tokenStream[programNum] = append(tokenStream[programNum], newToken)
+				} else {
+					var secondPos int = nextRune(liveRune, codeRunes, currentPos)
+					newToken = c.tokenize(string(liveRune)+string(codeRunes[secondPos]), line, lastPos-deadPos+1, quoteFlag)
+					c.keepSplitComment(codeRunes, currentPos, secondPos, Location{line: line, startPos: currentPos - deadPos + 2})
+					lastPos = secondPos + 1 // 2 rune symbol
+					currentPos = lastPos - 1
+					tokenStream[programNum] = append(tokenStream[programNum], newToken)
+				}
+
 				// open comment symbol - we want to keep buffer unaffected
 			} else if liveRune == '/' && currentPos < len(codeRunes)-1 && codeRunes[currentPos+1] == '*' {
 				commentFlag = true
 				lastCommentStart = currentPos
+				commentLoc = Location{line: line, startPos: currentPos - deadPos + 1}
 				if len(tokenBuffer) == 0 {
 					lastPos += 2 // open comment is 2 chars
 					currentPos = lastPos - 1
@@ -344,18 +450,18 @@ func Lex(filedata string) {
 					evaluateBuffer = true
 
 				} else { // error the invalid
+					var hint string
 					if unicode.IsUpper(liveRune) {
-						Error(fmt.Sprintf("Invalid token [ %c ] found at (%d:%d); "+
-							"Hint: Capital letters are not permitted.", liveRune, line, lastPos-deadPos+1), "LEXER")
-					} else if liveRune == '!' {
-						Error(fmt.Sprintf("Invalid token [ %c ] found at (%d:%d); "+
-							"Hint: possible malformed N-EQUAL_OP [ != ]", liveRune, line, lastPos-deadPos+1), "LEXER")
+						hint = "Capital letters are not permitted."
+					} else if liveRune == '&' {
+						hint = "possible malformed AND_OP [ && ]"
+					} else if liveRune == '|' {
+						hint = "possible malformed OR_OP [ || ]"
 					} else if liveRune == '/' || liveRune == '*' {
-						Error(fmt.Sprintf("Invalid token [ %c ] found at (%d:%d); "+
-							"Hint: possible malformed comment.", liveRune, line, lastPos-deadPos+1), "LEXER")
-					} else {
-						Error(fmt.Sprintf("Invalid token [ %c ] found at (%d:%d)", liveRune, line, lastPos-deadPos+1), "LEXER")
+						hint = "possible malformed comment."
 					}
+					c.report(SeverityError, StageLexer, CodeLexInvalidChar, Location{line: line, startPos: lastPos - deadPos + 1}, 1,
+						fmt.Sprintf("Invalid token [ %c ] found", liveRune), hint)
 					lastPos++
 					errorCount++
 				}
@@ -374,7 +480,7 @@ func Lex(filedata string) {
 		if evaluateBuffer {
 
 			greedyCapture = evaluateTokenBuffer(tokenBuffer) // check what we have
-			newToken = tokenize(greedyCapture, line, lastPos-deadPos+1, quoteFlag)
+			newToken = c.tokenize(greedyCapture, line, lastPos-deadPos+1, quoteFlag)
 
 			// buffer spans a comment
 			if len(tokenBuffer) < currentPos-lastPos {
@@ -402,11 +508,14 @@ func Lex(filedata string) {
 		}
 	}
 
+	var eofLoc Location = Location{line: line, startPos: lastPos - deadPos + 1}
 	if quoteFlag {
-		Error("EOF reached while inside string; Strings must be terminated.", "LEXER")
+		c.report(SeverityError, StageLexer, CodeLexUnterminatedString, eofLoc, 0,
+			"EOF reached while inside string", "Strings must be terminated.")
 		errorCount++
 	} else if commentFlag && !alreadyErrUntermComment {
-		Error("EOF reached while inside comment; Comments must be terminated.", "LEXER")
+		c.report(SeverityError, StageLexer, CodeLexUntermComment, eofLoc, 0,
+			"EOF reached while inside comment", "Comments must be terminated.")
 		errorCount++
 	}
 
@@ -414,25 +523,30 @@ func Lex(filedata string) {
 	if len(tokenStream) > 0 && len(tokenStream[len(tokenStream)-1]) > 0 &&
 		tokenStream[len(tokenStream)-1][len(tokenStream[len(tokenStream)-1])-1].content != "EOP" {
 
-		Warn("EOF reached before EOP [ $ ]; EOP token was automatically inserted.", "LEXER")
+		c.report(SeverityWarning, StageLexer, CodeLexMissingEOP, eofLoc, 0,
+			"EOF reached before EOP [ $ ]; EOP token was automatically inserted", "")
 		warningCount++
 
 		// artificially add EOP at end of last line - user will be told where
-		newToken = tokenize("$", line, lastPos-deadPos+1, quoteFlag)
+		newToken = c.tokenize("$", line, lastPos-deadPos+1, quoteFlag)
 		tokenStream[programNum] = append(tokenStream[programNum], newToken)
 
-		passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
+		c.passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
 
 	} else if !alreadyFailed && len(tokenStream[len(tokenStream)-1]) == 0 {
-		Warn("Code provided is only whitespace and/or comments! No tokens generated.", "LEXER")
+		c.report(SeverityWarning, StageLexer, CodeLexNoTokens, Location{}, 0,
+			"Code provided is only whitespace and/or comments! No tokens generated.", "")
 		warningCount++
-		Warn("EOF reached before EOP [ $ ]; EOP token was automatically inserted.", "LEXER")
+		c.report(SeverityWarning, StageLexer, CodeLexMissingEOP, eofLoc, 0,
+			"EOF reached before EOP [ $ ]; EOP token was automatically inserted", "")
 		warningCount++
 
 		// artificially add EOP at end of last line - user will be told where
-		newToken = tokenize("$", line, lastPos-deadPos+1, quoteFlag)
+		newToken = c.tokenize("$", line, lastPos-deadPos+1, quoteFlag)
 		tokenStream[programNum] = append(tokenStream[programNum], newToken)
 
-		passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
+		c.passFailProgram(programNum, errorCount, warningCount, tokenStream, &alreadyFailed)
 	}
+
+	return tokenStream, diags // diags filled in by the deferred func
 }
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"fmt"
+	"gopiler/internal/emulator"
+)
+
+const asmHeader string = "6502 Assembly:\n\t"
+const asmSeparator string = " \n\t"
+
+// Lowering gives the IR addresses: the code from $00, a byte per slot right after it,
+// and the heap (already placed by addToHeap) from the top down. Then bytes and assembly are written out.
+func (c *Compiler) lower() {
+	if c.optimize {
+		c.peephole()
+	}
+
+	var codeSize int = 0
+	for _, ins := range c.ir {
+		if ins.label != nil {
+			ins.label.addr = codeSize
+		}
+		codeSize += ins.size()
+	}
+	if codeSize >= c.topHeapPtr {
+		c.report(SeverityError, StageCodeGen, CodeGenMemoryExceeded, Location{}, 0, "Memory size exceeded (256 Bytes)", "")
+		c.genErrors++
+		return
+	}
+
+	// backpatch
+	c.endStackPtr = codeSize
+	for _, s := range usedSlots(c.ir, c.slots) {
+		s.addr = byte(c.endStackPtr)
+		c.endStackPtr++
+		if c.endStackPtr >= c.topHeapPtr {
+			c.report(SeverityError, StageCodeGen, CodeGenMemoryExceeded, Location{}, 0, "Memory size exceeded (256 Bytes)", "")
+			c.genErrors++
+			return
+		}
+	}
+
+	var addr int = 0
+	for _, ins := range c.ir {
+		if ins.label != nil {
+			continue
+		}
+		bytes, asm := ins.lower(addr)
+		copy(c.curMem[addr:], bytes)
+		c.curAsm = append(c.curAsm, asm+asmSeparator...)
+		addr += len(bytes)
+	}
+	copyAsm := make([]byte, len(c.curAsm))
+	copy(copyAsm, c.curAsm)
+	c.asmList[c.curProgram] = &copyAsm
+	c.irList[c.curProgram] = c.ir
+}
+
+// slots something still refers to, in the order they were made
+func usedSlots(code []*irInstr, slots []*slot) []*slot {
+	var referenced map[*slot]bool = make(map[*slot]bool)
+	for _, ins := range code {
+		if ins.operand.kind == operandSlot {
+			referenced[ins.operand.slot] = true
+		}
+	}
+	var used []*slot
+	for _, s := range slots {
+		if referenced[s] {
+			used = append(used, s)
+		}
+	}
+	return used
+}
+
+// machine code and assembly for an instruction at addr
+func (ins *irInstr) lower(addr int) ([]byte, string) {
+	var mnemonic string = emulator.Mnemonics[ins.opcode]
+	var operand irOperand = ins.operand
+	switch operand.kind {
+	case operandImmediate:
+		return []byte{ins.opcode, operand.value}, fmt.Sprintf("%s #$%02X", mnemonic, operand.value)
+	case operandLabel:
+		var offset byte = byte(operand.label.addr - (addr + 2)) // 2's comp, wraps like the PC
+		return []byte{ins.opcode, offset}, fmt.Sprintf("%s $%02X", mnemonic, offset)
+	case operandDistance:
+		var offset byte = byte(operand.label.addr - (operand.from.addr + 2))
+		return []byte{ins.opcode, offset}, fmt.Sprintf("%s #$%02X", mnemonic, offset)
+	case operandAbsolute, operandSlot, operandSelf, operandCodeByte:
+		var target byte = operand.value
+		if operand.kind == operandSlot {
+			target = operand.slot.addr
+		} else if operand.kind == operandSelf {
+			target = byte(addr)
+		} else if operand.kind == operandCodeByte {
+			target = byte(operand.label.addr) + operand.value
+		}
+		// little endian, the high byte is always 00
+		return []byte{ins.opcode, target, 0x00}, fmt.Sprintf("%s $00%02X", mnemonic, target)
+	}
+	return []byte{ins.opcode}, mnemonic
+}
@@ -5,14 +5,20 @@ import (
 	"strings"
 )
 
-// careful: this slice will update the 2d tokenstream from lexer!
-var tokens []Token
-var liveTokenIdx int = 0
-var liveToken Token
-var parseError bool = false
-var alternateWarning string
-var pNum int // program num
-var currentParent *Node
+// parser state - lives on the Compiler so that compilations do not share it
+type parserState struct {
+	// careful: this slice will update the 2d tokenstream from lexer!
+	tokens           []Token
+	liveTokenIdx     int
+	liveToken        Token
+	parseError       bool // set on a syntax error until we recover, every parse func bails while it is set
+	parseErrors      int  // syntax errors in this program - one is enough to fail it
+	lastErrorIdx     int  // token of the last reported error, so one bad token is reported once
+	alternateWarning string
+	pNum             int // program num
+	currentParent    *Node
+	cstList          []*TokenTree // hold on to the CSTs
+}
 
 // options for statement token
 var statementOptions map[string]struct{} = map[string]struct{}{
@@ -23,42 +29,87 @@ var statementOptions map[string]struct{} = map[string]struct{}{
 	"OPEN_BRACE": {},
 }
 
-// hold on to the CSTs
-var cstList []TokenTree
+// the AST node each boolop becomes
+var boolOps map[string]string = map[string]string{
+	"EQUAL_OP":      "<Equality>",
+	"N-EQUAL_OP":    "<Inequality>",
+	"AND_OP":        "<Conjunction>",
+	"OR_OP":         "<Disjunction>",
+	"LESS_OP":       "<LessThan>",
+	"GREATER_OP":    "<GreaterThan>",
+	"LESS_EQ_OP":    "<LessOrEqual>",
+	"GREATER_EQ_OP": "<GreaterOrEqual>",
+}
+
+// tokens we can safely resume parsing at after a syntax error
+// IDs are left out as they show up inside expressions too
+var syncTokens map[string]struct{} = map[string]struct{}{
+	"KEYW_PRINT":  {},
+	"KEYW_WHILE":  {},
+	"KEYW_IF":     {},
+	"OPEN_BRACE":  {},
+	"CLOSE_BRACE": {},
+	"EOP":         {},
+}
 
-func startCst(pNum int) {
+func (c *Compiler) startCst(pNum int) {
 	// if a program fails in lexer, it never even got to parse
 	// we still need to index using program num though
-	for len(cstList) <= pNum {
-		cstList = append(cstList, TokenTree{})
+	for len(c.cstList) <= pNum {
+		c.cstList = append(c.cstList, &TokenTree{})
 	}
 }
 
-func consumeCurrentToken(lastToken ...bool) {
+func (c *Compiler) consumeCurrentToken(lastToken ...bool) {
 	// this is just go syntax for an optional argument (variadic arg -  really a slice of bools)
 	endOfTokens := false
 	if len(lastToken) > 0 {
 		endOfTokens = lastToken[0]
 	}
 
-	Debug(fmt.Sprintf("\tFound terminal %s [ %s ] in token stream",
-		tokens[liveTokenIdx].content, tokens[liveTokenIdx].trueContent), "PARSER")
-	var newNode *Node = NewNode("Token", &tokens[liveTokenIdx])
-	currentParent.AddChild(newNode)
+	c.Debug(fmt.Sprintf("\tFound terminal %s [ %s ] in token stream",
+		c.tokens[c.liveTokenIdx].content, c.tokens[c.liveTokenIdx].trueContent), "PARSER")
+	var newNode *Node = NewNode("Token", &c.tokens[c.liveTokenIdx])
+	c.currentParent.AddChild(newNode)
+	c.alternateWarning = "" // any hint was about this token and it turned out fine
 
 	// don't go out of bounds
 	if !endOfTokens {
-		liveTokenIdx++
-		liveToken = tokens[liveTokenIdx]
+		c.liveTokenIdx++
+		c.liveToken = c.tokens[c.liveTokenIdx]
+	}
+}
+
+func (c *Compiler) wrongToken(expected string) {
+	c.parseError = true
+	if c.parseErrors > 0 && c.liveTokenIdx == c.lastErrorIdx {
+		// already complained about this token (unwinding blocks all want a } at the EOP)
+		c.alternateWarning = ""
+		return
 	}
+	c.parseErrors++
+	c.lastErrorIdx = c.liveTokenIdx
+	c.report(SeverityError, StageParser, CodeParseUnexpectedToken, c.liveToken.location, tokenWidth(&c.liveToken),
+		fmt.Sprintf("Expected %s. Found %s [ %s ]", expected, c.liveToken.content, c.liveToken.trueContent),
+		c.alternateWarning)
+	c.alternateWarning = ""
+}
+
+func isSyncToken(token *Token) bool {
+	_, exists := syncTokens[token.content]
+	return exists || (isTypeKeyword(token.trueContent) && token.tType == Keyword)
 }
 
-func wrongToken(expected string) {
-	Error(fmt.Sprintf("Error at (%d:%d). Expected %s. Found %s [ %s ]. %s",
-		liveToken.location.line, liveToken.location.startPos, expected,
-		liveToken.content, liveToken.trueContent, alternateWarning), "PARSER")
-	parseError = true
-	alternateWarning = ""
+// panic mode recovery: skip tokens until one we can pick back up at
+// startIdx is where the failed production began - we always move past it so we cannot loop
+// (-1 when nothing was consumed and the current token may be fine)
+func (c *Compiler) synchronize(startIdx int) {
+	for c.liveToken.content != "EOP" && (c.liveTokenIdx <= startIdx || !isSyncToken(&c.liveToken)) {
+		c.Debug(fmt.Sprintf("\tSkipping %s [ %s ] to recover", c.liveToken.content, c.liveToken.trueContent), "PARSER")
+		c.liveTokenIdx++
+		c.liveToken = c.tokens[c.liveTokenIdx]
+	}
+	c.parseError = false
 }
 
 func isTypeKeyword(candidate string) bool {
@@ -66,611 +117,644 @@ func isTypeKeyword(candidate string) bool {
 	return exists
 }
 
-func Parse(tokenStream []Token, programNum int) {
-	// recover from error (will pass it up to lexer, then main)
+// Parse builds the CST for one program's tokens.
+// The CST is nil if the program has a syntax error.
+func (c *Compiler) Parse(tokenStream []Token, programNum int) (cst *TokenTree, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
+	// recover from error so it does not explode the compiler
 	defer func() {
 		if r := recover(); r != nil {
-			CriticalError("parser", r)
+			c.CriticalError("parser", r)
+			cst = nil
 		}
+		diags = c.diagnosticsSince(firstDiag)
 	}()
 
-	Info(fmt.Sprintf("Parsing program %d", programNum+1), "GOPILER", true)
-	pNum = programNum
-	tokens = tokenStream
+	c.Info(fmt.Sprintf("Parsing program %d", programNum+1), "GOPILER", true)
+	c.pNum = programNum
+	c.curProgram = programNum
+	c.tokens = tokenStream
 	// starts at first token (pos 0)
-	liveToken = tokens[liveTokenIdx]
+	c.liveToken = c.tokens[c.liveTokenIdx]
 	// start new CST for this program
-	startCst(programNum)
+	c.startCst(programNum)
 
-	parseProgram()
+	c.parseProgram()
 
-	if !parseError {
-		Pass(fmt.Sprintf("Parser successfully evaluated program %d with no errors.", programNum+1), "PARSER")
-		Info(fmt.Sprintf("Program %d Concrete Syntax Tree (CST):\n%s\n%s", programNum+1, strings.Repeat("-", 75),
-			cstList[programNum].drawTree()), "GOPILER", true)
-		SemanticAnalysis(cstList[programNum], programNum)
+	if c.parseErrors == 0 {
+		c.Pass(fmt.Sprintf("Parser successfully evaluated program %d with no errors.", programNum+1), "PARSER")
+		c.Info(fmt.Sprintf("Program %d Concrete Syntax Tree (CST):\n%s\n%s", programNum+1, strings.Repeat("-", 75),
+			c.cstList[programNum].drawTree()), "GOPILER", true)
+		cst = c.cstList[programNum]
 	} else {
-		CreateFailedProgramVars(programNum, "parser")
-		Fail("Parsing aborted due to an error.", "PARSER")
-		errorMap[programNum] = "parser"
-		cstList[programNum] = TokenTree{} // free memory from the CST since it cannot be used
-		Info(fmt.Sprintf("Compilation of program %d aborted due to parser error.", programNum+1), "GOPILER", false)
+		c.CreateFailedProgramVars(programNum, "parser")
+		c.Fail(fmt.Sprintf("Parsing of program %d failed with %d error(s).", programNum+1, c.parseErrors), "PARSER")
+		c.errorMap[programNum] = "parser"
+		c.cstList[programNum] = &TokenTree{} // free memory from the CST since it cannot be used
+		c.Info(fmt.Sprintf("Compilation of program %d aborted due to parser error.", programNum+1), "GOPILER", false)
 	}
 
 	// reset global vars for next program
-	liveTokenIdx = 0
-	liveToken = Token{}
-	parseError = false
-	alternateWarning = ""
+	c.liveTokenIdx = 0
+	c.liveToken = Token{}
+	c.parseError = false
+	c.parseErrors = 0
+	c.lastErrorIdx = 0
+	c.alternateWarning = ""
 	// assign new empty slice (tokens no longer can update tokenStream)
-	tokens = []Token{}
-	currentParent = nil
+	c.tokens = []Token{}
+	c.currentParent = nil
+	return cst, diags
 }
 
 // match Block, EOP
-func parseProgram() {
-	Debug("! Parsing at Program Level !", "PARSER")
+func (c *Compiler) parseProgram() {
+	c.Debug("! Parsing at Program Level !", "PARSER")
 	// start off our CST
 	var progRootNode *Node = NewNode("<Program>", nil)
-	cstList[pNum].rootNode = progRootNode
-	currentParent = cstList[pNum].rootNode
+	c.cstList[c.pNum].rootNode = progRootNode
+	c.currentParent = c.cstList[c.pNum].rootNode
 
-	parseBlock()
+	c.parseBlock()
 
-	if parseError {
-		return
-	}
-	currentParent = cstList[pNum].rootNode
-	Debug("! Parsing at Program Level !", "PARSER")
+	c.currentParent = c.cstList[c.pNum].rootNode
+	c.Debug("! Parsing at Program Level !", "PARSER")
 	// don't consume if right as it is the end
-	if liveToken.content == "EOP" {
-		consumeCurrentToken(true)
+	if c.liveToken.content == "EOP" {
+		c.consumeCurrentToken(true)
 	} else {
-		wrongToken("EOP [ $ ]")
+		c.wrongToken("EOP [ $ ]")
 	}
 }
 
 // match Open Brace, StatementList, Close Brace
-func parseBlock() {
-	Debug("! Parsing at Block Level !", "PARSER")
+func (c *Compiler) parseBlock() {
+	c.Debug("! Parsing at Block Level !", "PARSER")
 	var blockNode *Node = NewNode("<Block>", nil)
-	currentParent.AddChild(blockNode)
-	currentParent = blockNode
+	c.currentParent.AddChild(blockNode)
+	c.currentParent = blockNode
 
-	if liveToken.content == "OPEN_BRACE" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	var opened bool = false
+	if c.liveToken.content == "OPEN_BRACE" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
+		opened = true
 	} else {
-		wrongToken("OPEN_BRACE [ { ]")
+		c.wrongToken("OPEN_BRACE [ { ]")
+		c.synchronize(-1) // the statements may still be fine
 	}
 
-	parseStatementList()
+	var startIdx int = c.liveTokenIdx
+	c.parseStatementList()
 
-	if parseError {
-		return
-	}
-	currentParent = blockNode
-	Debug("! Parsing at Block Level !", "PARSER")
-	if liveToken.content == "CLOSE_BRACE" && liveToken.tType == Symbol {
-		consumeCurrentToken()
-	} else {
-		wrongToken("CLOSE_BRACE [ } ]")
+	for {
+		if c.parseError {
+			c.synchronize(startIdx)
+		}
+		c.currentParent = blockNode
+		c.Debug("! Parsing at Block Level !", "PARSER")
+		if !opened && (c.liveToken.content == "CLOSE_BRACE" || c.liveToken.content == "EOP") {
+			return // never had a { so this } is someone else's and a missing one is not news
+		} else if c.liveToken.content == "CLOSE_BRACE" && c.liveToken.tType == Symbol {
+			c.consumeCurrentToken()
+			return
+		}
+		c.wrongToken("CLOSE_BRACE [ } ]")
+		startIdx = c.liveTokenIdx
+		c.synchronize(startIdx)
+		if c.liveToken.content == "EOP" {
+			return // leave it for the program
+		} else if c.liveToken.content != "CLOSE_BRACE" {
+			// found more statements after the junk
+			c.parseStatementList()
+		}
 	}
 }
 
 // [statement statementList] or epsilon
-func parseStatementList() {
-	if parseError {
+func (c *Compiler) parseStatementList() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at StatementList Level !", "PARSER")
+	c.Debug("! Parsing at StatementList Level !", "PARSER")
 	var statementListNode *Node = NewNode("<StatementList>", nil)
-	currentParent.AddChild(statementListNode)
-	currentParent = statementListNode
+	c.currentParent.AddChild(statementListNode)
+	c.currentParent = statementListNode
 
-	if _, exists := statementOptions[liveToken.content]; exists || isTypeKeyword(liveToken.trueContent) {
-		parseStatement()
+	if _, exists := statementOptions[c.liveToken.content]; exists || isTypeKeyword(c.liveToken.trueContent) {
+		var startIdx int = c.liveTokenIdx
+		c.parseStatement()
 
-		if parseError {
-			return
-		} else {
-			currentParent = statementListNode
-			parseStatementList()
+		if c.parseError {
+			c.synchronize(startIdx) // keep going so every error gets reported
 		}
+		c.currentParent = statementListNode
+		c.parseStatementList()
 	} else {
-		if liveToken.content != "OPEN_BRACE" && alternateWarning == "" {
-			alternateWarning = "Hint: Possibly missing element in: {PrintStatement, AssignmentStatement, VarDecl, WhileStatement, IfStatement, Block}"
+		if c.liveToken.content != "OPEN_BRACE" && c.alternateWarning == "" {
+			c.alternateWarning = "Possibly missing element in: {PrintStatement, AssignmentStatement, VarDecl, WhileStatement, IfStatement, Block}"
 		}
-		currentParent = statementListNode
-		epsilonProduction()
+		c.currentParent = statementListNode
+		c.epsilonProduction()
 	}
-	currentParent = statementListNode
+	c.currentParent = statementListNode
 }
 
 // PrintStatement | AssignmentStatement | VarDecl | WhileStatement | IfStatement | Block
-func parseStatement() {
-	if parseError {
+func (c *Compiler) parseStatement() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at Statement Level !", "PARSER")
+	c.Debug("! Parsing at Statement Level !", "PARSER")
 	var statementNode *Node = NewNode("<Statement>", nil)
-	currentParent.AddChild(statementNode)
-	currentParent = statementNode
+	c.currentParent.AddChild(statementNode)
+	c.currentParent = statementNode
 
 	// we can't get to this function unless one of these options is valid
-	if liveToken.content == "KEYW_PRINT" && liveToken.tType == Keyword {
-		parsePrintStatement()
-	} else if liveToken.content == "ID" && liveToken.tType == Identifier {
-		parseAssignmentStatement()
-	} else if isTypeKeyword(liveToken.trueContent) && liveToken.tType == Keyword {
-		parseVarDecl()
-	} else if liveToken.content == "KEYW_WHILE" && liveToken.tType == Keyword {
-		parseWhileStatement()
-	} else if liveToken.content == "KEYW_IF" && liveToken.tType == Keyword {
-		parseIfStatement()
-	} else if liveToken.content == "OPEN_BRACE" && liveToken.tType == Symbol {
-		parseBlock()
-	}
-	currentParent = statementNode
+	if c.liveToken.content == "KEYW_PRINT" && c.liveToken.tType == Keyword {
+		c.parsePrintStatement()
+	} else if c.liveToken.content == "ID" && c.liveToken.tType == Identifier {
+		c.parseAssignmentStatement()
+	} else if isTypeKeyword(c.liveToken.trueContent) && c.liveToken.tType == Keyword {
+		c.parseVarDecl()
+	} else if c.liveToken.content == "KEYW_WHILE" && c.liveToken.tType == Keyword {
+		c.parseWhileStatement()
+	} else if c.liveToken.content == "KEYW_IF" && c.liveToken.tType == Keyword {
+		c.parseIfStatement()
+	} else if c.liveToken.content == "OPEN_BRACE" && c.liveToken.tType == Symbol {
+		c.parseBlock()
+	}
+	c.currentParent = statementNode
 }
 
 // Match Print, Open Paren, Expr, Close Paren
-func parsePrintStatement() {
-	if parseError {
+func (c *Compiler) parsePrintStatement() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at PrintStatement Level !", "PARSER")
+	c.Debug("! Parsing at PrintStatement Level !", "PARSER")
 	var printStatementNode *Node = NewNode("<PrintStatement>", nil)
-	currentParent.AddChild(printStatementNode)
-	currentParent = printStatementNode
+	c.currentParent.AddChild(printStatementNode)
+	c.currentParent = printStatementNode
 
-	if liveToken.content == "KEYW_PRINT" && liveToken.tType == Keyword {
-		consumeCurrentToken()
+	if c.liveToken.content == "KEYW_PRINT" && c.liveToken.tType == Keyword {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("KEYW_PRINT [ print ]")
+		c.wrongToken("KEYW_PRINT [ print ]")
 	}
 
-	if liveToken.content == "OPEN_PAREN" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	if c.liveToken.content == "OPEN_PAREN" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("OPEN_PAREN [ ( ]")
+		c.wrongToken("OPEN_PAREN [ ( ]")
 	}
 
-	parseExpr()
+	c.parseExpr()
 
-	if parseError {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at PrintStatement Level !", "PARSER")
-	currentParent = printStatementNode
-	if liveToken.content == "CLOSE_PAREN" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	c.Debug("! Parsing at PrintStatement Level !", "PARSER")
+	c.currentParent = printStatementNode
+	if c.liveToken.content == "CLOSE_PAREN" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("CLOSE_PAREN [ ) ]")
+		c.wrongToken("CLOSE_PAREN [ ) ]")
 	}
 }
 
 // IntExpr | StringExpr | BooleanExpr | ID
-func parseExpr() {
-	if parseError {
+func (c *Compiler) parseExpr() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at Expression Level !", "PARSER")
+	c.Debug("! Parsing at Expression Level !", "PARSER")
 	var exprNode *Node = NewNode("<Expr>", nil)
-	currentParent.AddChild(exprNode)
-	currentParent = exprNode
-
-	if liveToken.content == "DIGIT" && liveToken.tType == Digit {
-		parseIntExpr()
-	} else if liveToken.content == "QUOTE" && liveToken.tType == Symbol {
-		parseStringExpr()
-	} else if (liveToken.content == "OPEN_PAREN" && liveToken.tType == Symbol) ||
-		((liveToken.content == "KEYW_TRUE" || liveToken.content == "KEYW_FALSE") && liveToken.tType == Keyword) {
-		parseBooleanExpr()
-	} else if liveToken.content == "ID" && liveToken.tType == Identifier {
-		parseID()
-	} else {
-		wrongToken("token in: {ID [ char ], IntExpr, StringExpr, BooleanExpr}")
-	}
-	currentParent = exprNode
+	c.currentParent.AddChild(exprNode)
+	c.currentParent = exprNode
+
+	if c.liveToken.content == "DIGIT" && c.liveToken.tType == Digit {
+		c.parseIntExpr()
+	} else if c.liveToken.content == "QUOTE" && c.liveToken.tType == Symbol {
+		c.parseStringExpr()
+	} else if ((c.liveToken.content == "OPEN_PAREN" || c.liveToken.content == "NOT_OP") && c.liveToken.tType == Symbol) ||
+		((c.liveToken.content == "KEYW_TRUE" || c.liveToken.content == "KEYW_FALSE") && c.liveToken.tType == Keyword) {
+		c.parseBooleanExpr()
+	} else if c.liveToken.content == "ID" && c.liveToken.tType == Identifier {
+		c.parseID()
+	} else {
+		c.wrongToken("token in: {ID [ char ], IntExpr, StringExpr, BooleanExpr}")
+	}
+	c.currentParent = exprNode
 }
 
 // [digit, intop, Expr] | digit
-func parseIntExpr() {
-	if parseError {
+func (c *Compiler) parseIntExpr() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at IntExpr Level !", "PARSER")
+	c.Debug("! Parsing at IntExpr Level !", "PARSER")
 	var intExprNode *Node = NewNode("<IntExpr>", nil)
-	currentParent.AddChild(intExprNode)
-	currentParent = intExprNode
+	c.currentParent.AddChild(intExprNode)
+	c.currentParent = intExprNode
 
-	if liveToken.content == "DIGIT" && liveToken.tType == Digit {
-		parseDigit()
+	if c.liveToken.content == "DIGIT" && c.liveToken.tType == Digit {
+		c.parseDigit()
 	} else {
-		wrongToken("DIGIT [ 0-9 ]")
+		c.wrongToken("DIGIT [ 0-255 ]")
 	}
 
 	// this one is optional since just a digit will suffice
-	currentParent = intExprNode
-	if parseError {
+	c.currentParent = intExprNode
+	if c.parseError {
 		return
-	} else if liveToken.content == "ADD" && liveToken.tType == Symbol {
-		parseIntOp()
-		currentParent = intExprNode
-		parseExpr()
-	} else if liveToken.content == "DIGIT" && liveToken.tType == Digit {
-		alternateWarning = "Hint: Possible missing ADD [ + ]."
+	} else if (c.liveToken.content == "ADD" || c.liveToken.content == "SUB") && c.liveToken.tType == Symbol {
+		c.parseIntOp()
+		c.currentParent = intExprNode
+		c.parseExpr()
+	} else if c.liveToken.content == "DIGIT" && c.liveToken.tType == Digit {
+		c.alternateWarning = "Possible missing ADD [ + ]."
 	}
-	currentParent = intExprNode
+	c.currentParent = intExprNode
 }
 
-// +
-func parseIntOp() {
-	if parseError {
+// + | -
+func (c *Compiler) parseIntOp() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at IntOp Level !", "PARSER")
+	c.Debug("! Parsing at IntOp Level !", "PARSER")
 	var intOpNode *Node = NewNode("<IntOp>", nil)
-	currentParent.AddChild(intOpNode)
-	currentParent = intOpNode
+	c.currentParent.AddChild(intOpNode)
+	c.currentParent = intOpNode
 
-	if liveToken.content == "ADD" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	if (c.liveToken.content == "ADD" || c.liveToken.content == "SUB") && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("ADD [ + ]")
+		c.wrongToken("token in: {ADD [ + ], SUB [ - ]}")
 	}
 }
 
 // char
-func parseDigit() {
-	if parseError {
+func (c *Compiler) parseDigit() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at Digit Level !", "PARSER")
+	c.Debug("! Parsing at Digit Level !", "PARSER")
 	var digitNode *Node = NewNode("<Digit>", nil)
-	currentParent.AddChild(digitNode)
-	currentParent = digitNode
+	c.currentParent.AddChild(digitNode)
+	c.currentParent = digitNode
 
-	if liveToken.content == "DIGIT" && liveToken.tType == Digit {
-		consumeCurrentToken()
+	if c.liveToken.content == "DIGIT" && c.liveToken.tType == Digit {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("DIGIT [ 0-9 ]")
+		c.wrongToken("DIGIT [ 0-255 ]")
 	}
 }
 
 // ", charlist, "
-func parseStringExpr() {
-	if parseError {
+func (c *Compiler) parseStringExpr() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at StringExpr Level !", "PARSER")
+	c.Debug("! Parsing at StringExpr Level !", "PARSER")
 	var strExprNode *Node = NewNode("<StringExpr>", nil)
-	currentParent.AddChild(strExprNode)
-	currentParent = strExprNode
+	c.currentParent.AddChild(strExprNode)
+	c.currentParent = strExprNode
 
-	if liveToken.content == "QUOTE" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	if c.liveToken.content == "QUOTE" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("QUOTE [ \" ]")
+		c.wrongToken("QUOTE [ \" ]")
 	}
 
-	if parseError {
+	if c.parseError {
 		return
 	} else {
-		currentParent = strExprNode
-		parseCharList()
+		c.currentParent = strExprNode
+		c.parseCharList()
 	}
 
-	currentParent = strExprNode
-	if parseError {
+	c.currentParent = strExprNode
+	if c.parseError {
 		return
-	} else if liveToken.content == "QUOTE" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	} else if c.liveToken.content == "QUOTE" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("QUOTE [ \" ]")
+		c.wrongToken("QUOTE [ \" ]")
 	}
 }
 
 // [char, CharList], [space, CharList], epsilon
-func parseCharList() {
-	if parseError {
+func (c *Compiler) parseCharList() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at CharList Level !", "PARSER")
+	c.Debug("! Parsing at CharList Level !", "PARSER")
 	var charListNode *Node = NewNode("<CharList>", nil)
-	currentParent.AddChild(charListNode)
-	currentParent = charListNode
+	c.currentParent.AddChild(charListNode)
+	c.currentParent = charListNode
 
 	// char includes space and chars
-	if liveToken.content == "CHAR" && liveToken.tType == Character {
-		parseChar()
-		parseCharList()
+	if c.liveToken.content == "CHAR" && c.liveToken.tType == Character {
+		c.parseChar()
+		c.parseCharList()
 	} else {
-		epsilonProduction()
+		c.epsilonProduction()
 	}
-	currentParent = charListNode
+	c.currentParent = charListNode
 }
 
 // char
-func parseChar() {
-	if parseError {
+func (c *Compiler) parseChar() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at Char Level !", "PARSER")
+	c.Debug("! Parsing at Char Level !", "PARSER")
 	var charNode *Node = NewNode("<Char>", nil)
-	currentParent.AddChild(charNode)
-	currentParent = charNode
+	c.currentParent.AddChild(charNode)
+	c.currentParent = charNode
 
-	if liveToken.content == "CHAR" && liveToken.tType == Character {
-		consumeCurrentToken()
+	if c.liveToken.content == "CHAR" && c.liveToken.tType == Character {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("CHAR [ a-z | (space) ]")
+		c.wrongToken("CHAR [ a-z | (space) ]")
 	}
 }
 
 // ID, =, Expr
-func parseAssignmentStatement() {
-	if parseError {
+func (c *Compiler) parseAssignmentStatement() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at AssignmentStatement Level !", "PARSER")
+	c.Debug("! Parsing at AssignmentStatement Level !", "PARSER")
 	var assignNode *Node = NewNode("<AssignmentStatement>", nil)
-	currentParent.AddChild(assignNode)
-	currentParent = assignNode
+	c.currentParent.AddChild(assignNode)
+	c.currentParent = assignNode
 
-	if liveToken.content == "ID" && liveToken.tType == Identifier {
-		parseID()
+	if c.liveToken.content == "ID" && c.liveToken.tType == Identifier {
+		c.parseID()
 	} else {
-		wrongToken("ID [ char ]")
+		c.wrongToken("ID [ char ]")
 	}
 
-	if parseError {
+	if c.parseError {
 		return
-	} else if liveToken.content == "ASSIGN_OP" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	} else if c.liveToken.content == "ASSIGN_OP" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("ASSIGN_OP [ = ]")
+		c.wrongToken("ASSIGN_OP [ = ]")
 	}
 
-	if parseError {
+	if c.parseError {
 		return
 	} else {
-		currentParent = assignNode
-		parseExpr()
+		c.currentParent = assignNode
+		c.parseExpr()
 	}
-	currentParent = assignNode
+	c.currentParent = assignNode
 }
 
 // ID
-func parseID() {
-	if parseError {
+func (c *Compiler) parseID() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at ID Level !", "PARSER")
+	c.Debug("! Parsing at ID Level !", "PARSER")
 	var idNode *Node = NewNode("<ID>", nil)
-	currentParent.AddChild(idNode)
-	currentParent = idNode
+	c.currentParent.AddChild(idNode)
+	c.currentParent = idNode
 
-	if liveToken.content == "ID" && liveToken.tType == Identifier {
-		consumeCurrentToken()
+	if c.liveToken.content == "ID" && c.liveToken.tType == Identifier {
+		c.consumeCurrentToken()
 	}
 }
 
 // type, id
-func parseVarDecl() {
-	if parseError {
+func (c *Compiler) parseVarDecl() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at VarDecl Level !", "PARSER")
+	c.Debug("! Parsing at VarDecl Level !", "PARSER")
 	var declNode *Node = NewNode("<VarDecl>", nil)
-	currentParent.AddChild(declNode)
-	currentParent = declNode
+	c.currentParent.AddChild(declNode)
+	c.currentParent = declNode
 
-	if isTypeKeyword(liveToken.trueContent) && liveToken.tType == Keyword {
-		parseType()
+	if isTypeKeyword(c.liveToken.trueContent) && c.liveToken.tType == Keyword {
+		c.parseType()
 	} else {
-		wrongToken("type keyword in: {I_TYPE [ int ], B_TYPE [ boolean ], S_TYPE [ string ]}")
+		c.wrongToken("type keyword in: {I_TYPE [ int ], B_TYPE [ boolean ], S_TYPE [ string ]}")
 	}
 
-	currentParent = declNode
+	c.currentParent = declNode
 
-	if parseError {
+	if c.parseError {
 		return
-	} else if liveToken.content == "ID" && liveToken.tType == Identifier {
-		parseID()
+	} else if c.liveToken.content == "ID" && c.liveToken.tType == Identifier {
+		c.parseID()
 	} else {
-		wrongToken("ID [ char ]")
+		c.wrongToken("ID [ char ]")
 	}
-	currentParent = declNode
+	c.currentParent = declNode
 }
 
 // type keywords
-func parseType() {
-	if parseError {
+func (c *Compiler) parseType() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at Type Level !", "PARSER")
+	c.Debug("! Parsing at Type Level !", "PARSER")
 	var typeNode *Node = NewNode("<Type>", nil)
-	currentParent.AddChild(typeNode)
-	currentParent = typeNode
+	c.currentParent.AddChild(typeNode)
+	c.currentParent = typeNode
 
-	if isTypeKeyword(liveToken.trueContent) && liveToken.tType == Keyword {
-		consumeCurrentToken()
+	if isTypeKeyword(c.liveToken.trueContent) && c.liveToken.tType == Keyword {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("type keyword in: {I_TYPE [ int ], B_TYPE [ boolean ], S_TYPE [ string ]}")
+		c.wrongToken("type keyword in: {I_TYPE [ int ], B_TYPE [ boolean ], S_TYPE [ string ]}")
 	}
 }
 
 // while, BooleanExpr, Block
-func parseWhileStatement() {
-	if parseError {
+func (c *Compiler) parseWhileStatement() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at WhileStatement Level !", "PARSER")
+	c.Debug("! Parsing at WhileStatement Level !", "PARSER")
 	var whileNode *Node = NewNode("<WhileStatement>", nil)
-	currentParent.AddChild(whileNode)
-	currentParent = whileNode
+	c.currentParent.AddChild(whileNode)
+	c.currentParent = whileNode
 
-	if liveToken.content == "KEYW_WHILE" && liveToken.tType == Keyword {
-		consumeCurrentToken()
+	if c.liveToken.content == "KEYW_WHILE" && c.liveToken.tType == Keyword {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("KEYW_WHILE [ while ]")
+		c.wrongToken("KEYW_WHILE [ while ]")
 	}
 
-	if parseError {
+	if c.parseError {
 		return
 	} else {
-		currentParent = whileNode
-		parseBooleanExpr()
+		c.currentParent = whileNode
+		c.parseBooleanExpr()
 	}
 
-	if parseError {
+	if c.parseError {
 		return
 	} else {
-		currentParent = whileNode
-		parseBlock()
+		c.currentParent = whileNode
+		c.parseBlock()
 	}
-	currentParent = whileNode
+	c.currentParent = whileNode
 }
 
-// [(, Expr, boolop, Expr, )] | boolval
-func parseBooleanExpr() {
-	if parseError {
+// [(, Expr, boolop, Expr, )] | [!, Expr] | boolval
+func (c *Compiler) parseBooleanExpr() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at BooleanExpression Level !", "PARSER")
+	c.Debug("! Parsing at BooleanExpression Level !", "PARSER")
 	var boolExprNode *Node = NewNode("<BooleanExpression>", nil)
-	currentParent.AddChild(boolExprNode)
-	currentParent = boolExprNode
+	c.currentParent.AddChild(boolExprNode)
+	c.currentParent = boolExprNode
 
-	if liveToken.content == "OPEN_PAREN" && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	if c.liveToken.content == "OPEN_PAREN" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 
-		parseExpr()
+		c.parseExpr()
 
-		if parseError {
+		if c.parseError {
 			return
 		} else {
-			currentParent = boolExprNode
-			parseBoolOp()
+			c.currentParent = boolExprNode
+			c.parseBoolOp()
 		}
 
-		if parseError {
+		if c.parseError {
 			return
 		} else {
-			currentParent = boolExprNode
-			parseExpr()
+			c.currentParent = boolExprNode
+			c.parseExpr()
 		}
 
-		if parseError {
+		if c.parseError {
 			return
-		} else if liveToken.content == "CLOSE_PAREN" && liveToken.tType == Symbol {
-			currentParent = boolExprNode
-			consumeCurrentToken()
+		} else if c.liveToken.content == "CLOSE_PAREN" && c.liveToken.tType == Symbol {
+			c.currentParent = boolExprNode
+			c.consumeCurrentToken()
 		} else {
-			wrongToken("CLOSE_PAREN [ ) ]")
+			c.wrongToken("CLOSE_PAREN [ ) ]")
 		}
 
+	} else if c.liveToken.content == "NOT_OP" && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
+		c.parseExpr()
+
 	} else {
-		currentParent = boolExprNode
-		parseBoolVal()
+		c.currentParent = boolExprNode
+		c.parseBoolVal()
 	}
-	currentParent = boolExprNode
+	c.currentParent = boolExprNode
 }
 
-// == | !=
-func parseBoolOp() {
-	if parseError {
+// == | != | && | || | < | > | <= | >=
+func (c *Compiler) parseBoolOp() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at BoolOp Level !", "PARSER")
+	c.Debug("! Parsing at BoolOp Level !", "PARSER")
 	var boolOpNode *Node = NewNode("<BoolOp>", nil)
-	currentParent.AddChild(boolOpNode)
-	currentParent = boolOpNode
+	c.currentParent.AddChild(boolOpNode)
+	c.currentParent = boolOpNode
 
-	if (liveToken.content == "EQUAL_OP" || liveToken.content == "N-EQUAL_OP") && liveToken.tType == Symbol {
-		consumeCurrentToken()
+	if _, isBoolOp := boolOps[c.liveToken.content]; isBoolOp && c.liveToken.tType == Symbol {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("token in: {EQUAL_OP [ == ], N-EQUAL_OP [ != ]}")
+		c.wrongToken("token in: {EQUAL_OP [ == ], N-EQUAL_OP [ != ], AND_OP [ && ], OR_OP [ || ], LESS_OP [ < ], GREATER_OP [ > ], LESS_EQ_OP [ <= ], GREATER_EQ_OP [ >= ]}")
 	}
 }
 
 // true | false
-func parseBoolVal() {
-	if parseError {
+func (c *Compiler) parseBoolVal() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at BoolVal Level !", "PARSER")
+	c.Debug("! Parsing at BoolVal Level !", "PARSER")
 	var boolValNode *Node = NewNode("<BoolVal>", nil)
-	currentParent.AddChild(boolValNode)
-	currentParent = boolValNode
+	c.currentParent.AddChild(boolValNode)
+	c.currentParent = boolValNode
 
-	if (liveToken.content == "KEYW_TRUE" || liveToken.content == "KEYW_FALSE") && liveToken.tType == Keyword {
-		consumeCurrentToken()
+	if (c.liveToken.content == "KEYW_TRUE" || c.liveToken.content == "KEYW_FALSE") && c.liveToken.tType == Keyword {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("token in: {KEYW_TRUE [ true ], KEYW_FALSE [ false ]}")
+		c.wrongToken("token in: {KEYW_TRUE [ true ], KEYW_FALSE [ false ]}")
 	}
 }
 
-// if, BooleanExpr, Block
-func parseIfStatement() {
-	if parseError {
+// if, BooleanExpr, Block, [else, Block]
+func (c *Compiler) parseIfStatement() {
+	if c.parseError {
 		return
 	}
-	Debug("! Parsing at IfStatement Level !", "PARSER")
+	c.Debug("! Parsing at IfStatement Level !", "PARSER")
 	var ifNode *Node = NewNode("<IfStatement>", nil)
-	currentParent.AddChild(ifNode)
-	currentParent = ifNode
+	c.currentParent.AddChild(ifNode)
+	c.currentParent = ifNode
 
-	if liveToken.content == "KEYW_IF" && liveToken.tType == Keyword {
-		consumeCurrentToken()
+	if c.liveToken.content == "KEYW_IF" && c.liveToken.tType == Keyword {
+		c.consumeCurrentToken()
 	} else {
-		wrongToken("KEYW_IF [ if ]")
+		c.wrongToken("KEYW_IF [ if ]")
 	}
 
-	if parseError {
+	if c.parseError {
 		return
 	} else {
-		parseBooleanExpr()
+		c.parseBooleanExpr()
 	}
 
-	if parseError {
+	if c.parseError {
 		return
 	} else {
-		currentParent = ifNode
-		parseBlock()
+		c.currentParent = ifNode
+		c.parseBlock()
+	}
+
+	// else is optional
+	if !c.parseError && c.liveToken.content == "KEYW_ELSE" && c.liveToken.tType == Keyword {
+		c.currentParent = ifNode
+		c.consumeCurrentToken()
+		c.parseBlock()
 	}
-	currentParent = ifNode
+	c.currentParent = ifNode
 }
 
-func epsilonProduction() {
+func (c *Compiler) epsilonProduction() {
 	/* This is an epsilon production
 	No real work will occur here.
 	Implemented for code readability */
-	Debug(fmt.Sprintf("\tEpsilon [ %c ] production", '\u03B5'), "PARSER")
+	c.Debug(fmt.Sprintf("\tEpsilon [ %c ] production", '\u03B5'), "PARSER")
 	var epsToken = Token{
 		tType:       Symbol,
 		content:     "EPS",
 		trueContent: "\u03B5",
 	}
 	var newNode *Node = NewNode("Token", &epsToken)
-	currentParent.AddChild(newNode)
+	c.currentParent.AddChild(newNode)
 }
 
-func GetCst() string {
-	if len(cstList) == 0 {
+func (c *Compiler) GetCst() string {
+	if len(c.cstList) == 0 {
 		return fmt.Sprintf("Program 1\n%s\nNo CST generated due to %s error\n\n",
-			strings.Repeat("-", 75), errorMap[0])
+			strings.Repeat("-", 75), c.errorMap[0])
 	}
 
 	var cstString string = ""
-	for i, cst := range cstList {
+	for i, cst := range c.cstList {
 		// don't display programs that had error before CST generation is complete
 		cstString += fmt.Sprintf("Program %d\n%s", i+1, strings.Repeat("-", 75))
-		if !hadError(i) || (errorMap[i] != "parser" && errorMap[i] != "lexer") {
+		if !c.hadError(i) || (c.errorMap[i] != "parser" && c.errorMap[i] != "lexer") {
 			cstString += cst.drawTree() + "\n"
 		} else {
-			cstString += fmt.Sprintf("\nNo CST generated due to %s error\n\n", errorMap[i])
+			cstString += fmt.Sprintf("\nNo CST generated due to %s error\n\n", c.errorMap[i])
 		}
 	}
 	return cstString
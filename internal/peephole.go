@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"fmt"
+)
+
+/* Peephole optimizer.
+Runs over a program's IR right before lowering, so nothing has an address yet
+and removing code or temporaries just means fewer bytes once it is laid out.
+The IR is rewritten by a handful of patterns until none of them match.
+
+Every pattern relies on the same facts about what the generator emits:
+	- $00FF is scratch, always stored right before it is read
+	- statements load A, X and Y themselves, so none survive between statements
+	- only CPX sets Z (and only CPX, ADC and SBC set C), so loads can sit between a compare and its branch */
+
+type peepholeRule struct {
+	name  string
+	apply func(code []*irInstr, i int) (replaced int, with []*irInstr)
+}
+
+// tried in order at every instruction
+var peepholeRules = []peepholeRule{
+	{"comparison branches", fuseComparisonBranch},
+	{"constant branches", foldConstantBranch},
+	{"print temporaries", foldPrintTemp},
+	{"comparison loads", foldComparisonLoad},
+	{"unconditional branches", shrinkZFlagZero},
+}
+
+func (c *Compiler) SetOptimize(toggle bool) {
+	c.optimize = toggle
+}
+
+func (c *Compiler) peephole() {
+	beforeCode, beforeStatics := irSize(c.ir, c.slots)
+
+	var counts map[string]int = make(map[string]int)
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(c.ir); i++ {
+			for _, rule := range peepholeRules {
+				replaced, with := rule.apply(c.ir, i)
+				if replaced == 0 || !onlyEnteredAtStart(c.ir, i, replaced) {
+					continue
+				}
+				var spliced []*irInstr = append([]*irInstr{}, c.ir[:i]...)
+				spliced = append(spliced, with...)
+				c.ir = append(spliced, c.ir[i+replaced:]...)
+				counts[rule.name]++
+				changed = true
+				break
+			}
+		}
+	}
+
+	for _, rule := range peepholeRules {
+		if counts[rule.name] > 0 {
+			c.Debug(fmt.Sprintf("Rewrote %d %s", counts[rule.name], rule.name), "OPTIMIZER")
+		}
+	}
+	afterCode, afterStatics := irSize(c.ir, c.slots)
+	c.Pass(fmt.Sprintf("Optimizer saved %d byte(s) in program %d (code %d -> %d bytes, statics %d -> %d bytes)",
+		beforeCode+beforeStatics-afterCode-afterStatics, c.curProgram+1, beforeCode, afterCode, beforeStatics, afterStatics), "OPTIMIZER")
+}
+
+// bytes of code and statics the IR will take once lowered
+func irSize(code []*irInstr, slots []*slot) (int, int) {
+	var size int = 0
+	for _, ins := range code {
+		size += ins.size()
+	}
+	return size, len(usedSlots(code, slots))
+}
+
+// nothing outside may branch to (or patch) a label a rule removes
+func onlyEnteredAtStart(code []*irInstr, start int, length int) bool {
+	var inside map[*label]bool = make(map[*label]bool)
+	for _, ins := range code[start : start+length] {
+		if ins.label != nil {
+			inside[ins.label] = true
+		}
+	}
+	for i, ins := range code {
+		if i >= start && i < start+length {
+			continue
+		}
+		for _, l := range ins.operand.labels() {
+			if inside[l] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// true if code[i:] starts with these opcodes, with no labels between them
+func matchOpcodes(code []*irInstr, i int, opcodes ...byte) bool {
+	if i+len(opcodes) > len(code) {
+		return false
+	}
+	for j, opcode := range opcodes {
+		if code[i+j].label != nil || code[i+j].opcode != opcode {
+			return false
+		}
+	}
+	return true
+}
+
+func isLabel(code []*irInstr, i int, l *label) bool {
+	return i < len(code) && code[i].label == l
+}
+
+func isScratch(ins *irInstr) bool {
+	return ins.operand.kind == operandAbsolute && ins.operand.value == 0xFF
+}
+
+func isImmediate(ins *irInstr, value byte) bool {
+	return ins.operand.kind == operandImmediate && ins.operand.value == value
+}
+
+// LDA #$01, STA $00FF, LDX #$00, CPX $00FF - what zFlagZero emits
+func isZFlagZero(code []*irInstr, i int) bool {
+	return matchOpcodes(code, i, 0xA9, 0x8D, 0xA2, 0xEC) &&
+		isImmediate(code[i], 0x01) && isScratch(code[i+1]) && isImmediate(code[i+2], 0x00) && isScratch(code[i+3])
+}
+
+// BNE neg; <zFlagZero>; LDA #pos; BNE done; neg: LDA #neg; done: - a comparison leaving 1 or 0 in A
+// (orderings branch with BCC or BCS instead of the first BNE)
+func isMaterializedCompare(code []*irInstr, i int) bool {
+	var branches bool = matchOpcodes(code, i, 0xD0) || matchOpcodes(code, i, 0x90) || matchOpcodes(code, i, 0xB0)
+	return branches && isZFlagZero(code, i+1) && matchOpcodes(code, i+5, 0xA9, 0xD0) &&
+		isLabel(code, i+7, code[i].operand.label) && matchOpcodes(code, i+8, 0xA9) &&
+		isLabel(code, i+9, code[i+6].operand.label) && code[i+5].operand.kind == operandImmediate &&
+		code[i+8].operand.kind == operandImmediate
+}
+
+func newInstr(opcode byte, operand irOperand) *irInstr {
+	return &irInstr{opcode: opcode, operand: operand}
+}
+
+// An if or while over a comparison first turns the compare into a 1 or 0 in A,
+// then stores it and compares that against 1 to decide whether to skip the block:
+//
+//	BNE neg; <zFlagZero>; LDA #pos; BNE done; neg: LDA #neg; done: STA $00FF; LDX #$01; CPX $00FF; BNE skip
+//
+// Branching on the first compare does the same thing.
+func fuseComparisonBranch(code []*irInstr, i int) (int, []*irInstr) {
+	if !isMaterializedCompare(code, i) || !matchOpcodes(code, i+10, 0x8D, 0xA2, 0xEC, 0xD0) ||
+		!isScratch(code[i+10]) || !isImmediate(code[i+11], 0x01) || !isScratch(code[i+12]) {
+		return 0, nil
+	}
+	var positive byte = code[i+5].operand.value
+	var negative byte = code[i+8].operand.value
+	var skip *irInstr = code[i+13]
+
+	if positive == 0x01 && negative == 0x00 { // ==, or an ordering
+		return 14, []*irInstr{newInstr(code[i].opcode, skip.operand)}
+	} else if positive == 0x00 && negative == 0x01 { // != still needs an unconditional branch to skip
+		var with []*irInstr = append([]*irInstr{}, code[i:i+5]...)
+		return 14, append(with, skip, code[i+7])
+	}
+	return 0, nil
+}
+
+// if (true) and while (false) store a constant only to compare it against 1:
+//
+//	LDA #k; STA $00FF; LDX #$01; CPX $00FF; BNE skip
+//
+// A 1 never branches, anything else always does.
+func foldConstantBranch(code []*irInstr, i int) (int, []*irInstr) {
+	if !matchOpcodes(code, i, 0xA9, 0x8D, 0xA2, 0xEC, 0xD0) || code[i].operand.kind != operandImmediate ||
+		!isScratch(code[i+1]) || !isImmediate(code[i+2], 0x01) || !isScratch(code[i+3]) {
+		return 0, nil
+	}
+	if code[i].operand.value == 0x01 {
+		return 5, nil
+	}
+	return 5, append(unconditional(), code[i+4])
+}
+
+// Printing a sum or comparison stores it to a temporary just to load it into Y:
+//
+//	LDA #k; STA t; LDY t                         ->  LDY #k
+//	<compare leaving 1 or 0 in A>; STA t; LDY t  ->  the same compare leaving it in Y
+//	STA t; LDY t                                 ->  STA $00FF; LDY $00FF
+func foldPrintTemp(code []*irInstr, i int) (int, []*irInstr) {
+	if isMaterializedCompare(code, i) && isPrintTemp(code, i+10) {
+		var with []*irInstr = append([]*irInstr{}, code[i:i+10]...)
+		with[5] = newInstr(0xA0, code[i+5].operand)
+		with[8] = newInstr(0xA0, code[i+8].operand)
+		return 12, with
+	}
+	if matchOpcodes(code, i, 0xA9) && code[i].operand.kind == operandImmediate && isPrintTemp(code, i+1) {
+		return 3, []*irInstr{newInstr(0xA0, code[i].operand)}
+	}
+	if isPrintTemp(code, i) {
+		return 2, []*irInstr{newInstr(0x8D, scratch()), newInstr(0xAC, scratch())}
+	}
+	return 0, nil
+}
+
+// STA t; LDY t for a temporary nothing else uses
+func isPrintTemp(code []*irInstr, i int) bool {
+	return matchOpcodes(code, i, 0x8D, 0xAC) && code[i].operand.kind == operandSlot &&
+		code[i].operand.slot.symbol == nil && code[i+1].operand == code[i].operand
+}
+
+// The right side of a comparison goes through $00FF to get into X:
+//
+//	LDA x; STA $00FF; LDX $00FF; CPX  ->  LDX x; CPX
+//
+// Both ways out of the comparison load A again, so it never needed the value.
+func foldComparisonLoad(code []*irInstr, i int) (int, []*irInstr) {
+	if !matchOpcodes(code, i+1, 0x8D, 0xAE, 0xEC) || !isScratch(code[i+1]) || !isScratch(code[i+2]) {
+		return 0, nil
+	}
+	if matchOpcodes(code, i, 0xA9) {
+		return 3, []*irInstr{newInstr(0xA2, code[i].operand)}
+	} else if matchOpcodes(code, i, 0xAD) {
+		return 3, []*irInstr{newInstr(0xAE, code[i].operand)}
+	}
+	return 0, nil
+}
+
+// zFlagZero stores a 1 to compare it with a 0 in X, when any nonzero byte will do.
+// The opcode of the compare itself is one we always know:
+//
+//	<zFlagZero> BNE  ->  LDX #$00; CPX <itself>; BNE
+//
+// Whatever is loaded for a branch can sit between them, and nothing after a BNE reads A.
+func shrinkZFlagZero(code []*irInstr, i int) (int, []*irInstr) {
+	if !isZFlagZero(code, i) {
+		return 0, nil
+	}
+	if matchOpcodes(code, i+4, 0xD0) || matchOpcodes(code, i+4, 0xA9, 0xD0) || matchOpcodes(code, i+4, 0xA0, 0xD0) {
+		return 4, unconditional()
+	}
+	return 0, nil
+}
+
+// leaves Z clear so the next BNE is always taken
+func unconditional() []*irInstr {
+	return []*irInstr{newInstr(0xA2, immediate(0x00)), newInstr(0xEC, selfOperand())}
+}
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// every program that compiles both ways must behave the same once optimized
+func TestPeepholeMatchesUnoptimized(t *testing.T) {
+	var compared int = 0
+	var rescued int = 0
+	for _, name := range testCases(t) {
+		var plain *caseResult = compileCaseWith(t, name, false)
+		var optimized *caseResult = compileCaseWith(t, name, true)
+
+		for pNum := range optimized.programs {
+			if optimized.programs[pNum].image == nil {
+				if plain.programs[pNum].image != nil {
+					t.Errorf("%s program %d: compiles without the optimizer but not with it", filepath.ToSlash(name), pNum+1)
+				}
+				continue
+			} else if plain.programs[pNum].image == nil {
+				rescued++ // nothing to compare against
+				continue
+			}
+			compared++
+
+			wantOutput, wantDiags := plain.compiler.Run(pNum, expectStepLimit)
+			gotOutput, gotDiags := optimized.compiler.Run(pNum, expectStepLimit)
+			// a program stopped by the step limit gets further once it is faster
+			var matches bool = gotOutput == wantOutput
+			if len(wantDiags) > 0 {
+				matches = strings.HasPrefix(gotOutput, wantOutput)
+			}
+			if !matches || len(gotDiags) != len(wantDiags) {
+				t.Errorf("%s program %d: optimized printed %q (%d diagnostics), unoptimized printed %q (%d diagnostics)",
+					filepath.ToSlash(name), pNum+1, gotOutput, len(gotDiags), wantOutput, len(wantDiags))
+			}
+		}
+	}
+	if compared == 0 {
+		t.Error("no program compiled both ways")
+	}
+	if rescued == 0 {
+		t.Error("the optimizer did not make any program fit that did not before")
+	}
+}
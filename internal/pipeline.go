@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// order the passes run in
+var stageOrder = map[Stage]int{
+	StageLexer:    0,
+	StageParser:   1,
+	StageSemantic: 2,
+	StageCodeGen:  3,
+}
+
+// names accepted for a stop-after stage
+var stageNames = map[string]Stage{
+	"lexer":    StageLexer,
+	"parser":   StageParser,
+	"semantic": StageSemantic,
+	"codegen":  StageCodeGen,
+}
+
+func ParseStage(name string) (Stage, error) {
+	stage, exists := stageNames[strings.ToLower(name)]
+	if !exists {
+		return "", fmt.Errorf("unknown stage %q; expected one of lexer, parser, semantic, codegen", name)
+	}
+	return stage, nil
+}
+
+// should stage run when compiling up to stopAfter
+func stageEnabled(stage Stage, stopAfter Stage) bool {
+	return stageOrder[stage] <= stageOrder[stopAfter]
+}
+
+// Compile runs every program in the source through the passes, stopping after stopAfter.
+// Each program's artifacts stay on the Compiler (GetCst, GetAst, GetMachineCode...).
+// Returns the diagnostics of every pass that ran, in order.
+func (c *Compiler) Compile(src string, stopAfter Stage) []Diagnostic {
+	programs, diags := c.Lex(src)
+
+	for pNum, tokens := range programs {
+		if tokens == nil || !stageEnabled(StageParser, stopAfter) {
+			continue // failed lexing or we are done
+		}
+		cst, passDiags := c.Parse(tokens, pNum)
+		diags = append(diags, passDiags...)
+		if cst == nil || !stageEnabled(StageSemantic, stopAfter) {
+			continue
+		}
+
+		ast, passDiags := c.BuildAST(cst, pNum)
+		diags = append(diags, passDiags...)
+		if ast == nil {
+			continue
+		}
+		symbols, passDiags := c.Analyze(ast, pNum)
+		diags = append(diags, passDiags...)
+		if symbols == nil {
+			continue
+		}
+		folded, passDiags := c.Fold(ast, pNum)
+		diags = append(diags, passDiags...)
+		if folded == nil || !stageEnabled(StageCodeGen, stopAfter) {
+			continue
+		}
+
+		_, passDiags = c.Generate(folded, symbols, pNum)
+		diags = append(diags, passDiags...)
+	}
+	return diags
+}
@@ -0,0 +1,84 @@
+package internal
+
+import "sort"
+
+// A SymbolRef is one place a symbol appears in the source: its declaration or a use.
+// Editors use these for hover, go to definition, and outlines.
+type SymbolRef struct {
+	Program  int      `json:"program"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Scope    string   `json:"scope"` // scope the symbol was declared in
+	Start    Position `json:"start"`
+	End      Position `json:"end"` // exclusive like diagnostics
+	Declared Position `json:"declared"`
+	IsDecl   bool     `json:"isDecl"`
+}
+
+// where a reference is, so each is only recorded once
+type referenceKey struct {
+	program int
+	line    int
+	column  int
+}
+
+// called by the analyzer whenever an ID resolves to an entry
+func (c *Compiler) addReference(entry *SymbolEntry, scope string, loc Location, isDecl bool) {
+	var key referenceKey = referenceKey{c.curProgram, loc.line, loc.startPos}
+	if c.referenceAt[key] {
+		return // expressions can get type checked more than once
+	}
+	if c.referenceAt == nil {
+		c.referenceAt = make(map[referenceKey]bool)
+	}
+	c.referenceAt[key] = true
+	c.references = append(c.references, SymbolRef{
+		Program:  c.curProgram,
+		Name:     entry.name,
+		Type:     entry.dataType,
+		Scope:    scope,
+		Start:    Position{Line: loc.line, Column: loc.startPos},
+		End:      Position{Line: loc.line, Column: loc.startPos + len(entry.name)},
+		Declared: Position{Line: entry.position.line, Column: entry.position.startPos},
+		IsDecl:   isDecl,
+	})
+}
+
+// the symbol covering a line and column of the source, if any
+// programs that failed analysis still have the references found before the error
+func (c *Compiler) SymbolAt(line int, column int) (SymbolRef, bool) {
+	for _, ref := range c.references {
+		if ref.Start.Line == line && ref.Start.Column <= column && column < ref.End.Column {
+			return ref, true
+		}
+	}
+	return SymbolRef{}, false
+}
+
+// every declaration in a program, in source order
+func (c *Compiler) Declarations(program int) []SymbolRef {
+	var decls []SymbolRef
+	for _, ref := range c.references {
+		if ref.Program == program && ref.IsDecl {
+			decls = append(decls, ref)
+		}
+	}
+	return decls
+}
+
+// every declaration and use of the symbol ref refers to, in source order
+func (c *Compiler) ReferencesTo(ref SymbolRef) []SymbolRef {
+	var refs []SymbolRef
+	for _, other := range c.references {
+		if other.Program == ref.Program && other.Name == ref.Name && other.Declared == ref.Declared {
+			refs = append(refs, other)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Start.Line != refs[j].Start.Line {
+			return refs[i].Start.Line < refs[j].Start.Line
+		}
+		return refs[i].Start.Column < refs[j].Start.Column
+	})
+	return refs
+}
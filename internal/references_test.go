@@ -0,0 +1,40 @@
+package internal
+
+import "testing"
+
+func TestReferences(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	c.Compile("{int a a = 1 + a\n{string a a = \"x\"} print((a == a))}$\n{int b b = 2}$", StageCodeGen)
+
+	// type checking visits some of these twice, but each place is recorded once
+	if len(c.references) != 9 {
+		t.Errorf("%d references, want 9: %v", len(c.references), c.references)
+	}
+
+	ref, found := c.SymbolAt(1, 16) // the a in 1 + a
+	if !found || ref.Name != "a" || ref.Type != "int" || ref.Scope != "0" || ref.IsDecl ||
+		ref.Declared != (Position{Line: 1, Column: 6}) || ref.Start != (Position{Line: 1, Column: 16}) {
+		t.Errorf("SymbolAt(1, 16) = %+v", ref)
+	}
+	if ref, _ := c.SymbolAt(2, 9); ref.Type != "string" || ref.Scope != "1.0" || !ref.IsDecl {
+		t.Errorf("SymbolAt(2, 9) = %+v, want the inner string declaration", ref)
+	}
+	if _, found := c.SymbolAt(1, 11); found {
+		t.Error("found a symbol on a digit")
+	}
+
+	var uses []SymbolRef = c.ReferencesTo(ref)
+	if len(uses) != 5 || !uses[0].IsDecl || uses[2] != ref || uses[4].Start != (Position{Line: 2, Column: 32}) {
+		t.Errorf("references to the outer a: %+v, want all but the inner a's", uses)
+	}
+
+	var decls []SymbolRef = c.Declarations(0)
+	if len(decls) != 2 || decls[0].Type != "int" || decls[1].Type != "string" {
+		t.Errorf("program 1 declarations %+v, want a then the inner a", decls)
+	}
+	if decls := c.Declarations(1); len(decls) != 1 || decls[0].Name != "b" || decls[0].Program != 1 {
+		t.Errorf("program 2 declarations %+v, want b", decls)
+	}
+}
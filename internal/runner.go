@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"gopiler/internal/emulator"
+	"strings"
+)
+
+// how many programs the last Lex found
+func (c *Compiler) ProgramCount() int {
+	return c.programCount
+}
+
+// Run executes a compiled program on the emulator and logs what it printed.
+// maxSteps bounds the instructions executed (no limit if maxSteps <= 0) so infinite loops end.
+func (c *Compiler) Run(program int, maxSteps int) (output string, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
+	c.curProgram = program
+
+	var image *[256]byte = c.GetMemoryImage(program)
+	if image == nil {
+		c.Info(fmt.Sprintf("Program %d was not run as it did not compile.", program+1), "GOPILER", true)
+		return "", nil
+	}
+
+	c.Info(fmt.Sprintf("Running program %d", program+1), "GOPILER", true)
+	var cpu *emulator.CPU = emulator.New(*image)
+	output, err := cpu.Run(maxSteps)
+	c.Info(fmt.Sprintf("Program %d Output:\n%s\n%s\n%s", program+1, strings.Repeat("-", 75),
+		output, strings.Repeat("-", 75)), "GOPILER", true)
+
+	if errors.Is(err, emulator.ErrStepLimit) {
+		c.report(SeverityError, StageRuntime, CodeRunStepLimit, Location{}, 0,
+			fmt.Sprintf("Program %d stopped: step limit exceeded after %d instructions", program+1, cpu.Steps),
+			"Possible infinite loop.")
+	} else if err != nil {
+		c.report(SeverityError, StageRuntime, CodeRunFault, Location{}, 0,
+			fmt.Sprintf("Program %d stopped: %v", program+1, err), "")
+	} else {
+		c.Pass(fmt.Sprintf("Program %d halted after %d instructions.", program+1, cpu.Steps), "EMULATOR")
+	}
+	return output, c.diagnosticsSince(firstDiag)
+}
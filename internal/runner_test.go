@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"errors"
+	"gopiler/internal/emulator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// general/infinite-loop compiles and is stopped by the step limit instead of hanging
+func TestRunStopsInfiniteLoop(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join(testCaseDir, "general", "infinite-loop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	c.Compile(string(src), StageCodeGen)
+	var image *[256]byte = c.GetMemoryImage(0)
+	if image == nil {
+		t.Fatalf("did not compile: %v", c.Diagnostics())
+	}
+
+	output, err := emulator.New(*image).Run(expectStepLimit)
+	if !errors.Is(err, emulator.ErrStepLimit) {
+		t.Fatalf("emulator gave %v, want the step limit", err)
+	}
+	var loop string = " this will always be true hahahahahahaha"
+	if !strings.HasPrefix(output, "a now is two a now is three"+loop+loop) {
+		t.Errorf("printed %q before it was stopped", output)
+	}
+
+	runOutput, diags := c.Run(0, expectStepLimit)
+	if runOutput != output || len(diags) != 1 || diags[0].Code != CodeRunStepLimit || diags[0].Severity != SeverityError {
+		t.Errorf("Run gave %v, want one RUN-STEP-LIMIT error", diags)
+	}
+}
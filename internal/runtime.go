@@ -0,0 +1,93 @@
+package internal
+
+/* Runtime routines.
+Some things take too much code to emit every time they are used, so they are emitted
+once after the BRK that ends a program and branched to. There is no JSR/RTS (or any
+indexed addressing), so the routines rewrite their own code: callers patch the offset
+of the routine's last BNE to come back to them, and addresses are read by patching
+them into the operands of loads. Nothing survives between calls except the code itself. */
+
+// compares two heap strings byte by byte, leaving 1 in $00FF if they match and 0 if not
+type stringCompare struct {
+	entry *label
+	ret   *label // the BNE back to the caller
+	loadL *label // callers patch the strings' addresses into these loads
+	loadR *label
+}
+
+// the program's string compare, made the first time one is needed
+func (c *Compiler) useStringCompare() *stringCompare {
+	if c.strCompare == nil {
+		c.strCompare = &stringCompare{
+			entry: c.newLabel(),
+			ret:   c.newLabel(),
+			loadL: c.newLabel(),
+			loadR: c.newLabel(),
+		}
+	}
+	return c.strCompare
+}
+
+// Sets Z if the strings left and right evaluate to have the same contents
+func (c *Compiler) generateStringCompare(left *Node, right *Node) {
+	var routine *stringCompare = c.useStringCompare()
+	c.generateComparison(left)
+	c.emit(0x8D, codeByte(routine.loadL, 1))
+	c.generateComparison(right)
+	c.emit(0x8D, codeByte(routine.loadR, 1))
+
+	// point the routine's return at us
+	var back *label = c.newLabel()
+	c.emit(0xA9, distance(routine.ret, back))
+	c.emit(0x8D, codeByte(routine.ret, 1))
+	c.alwaysBranch(routine.entry)
+
+	c.placeLabel(back)
+	c.emit(0xA2, immediate(0x01))
+	c.emit(0xEC, scratch()) // Z is set if it left a 1
+}
+
+// emitted after the BRK if anything called it
+func (c *Compiler) generateRuntime() {
+	if c.strCompare == nil {
+		return
+	}
+	var routine *stringCompare = c.strCompare
+	var differ *label = c.newLabel()
+	var next *label = c.newLabel()
+
+	c.placeLabel(routine.entry)
+	c.emit(0xA9, immediate(0x01)) // a match until a byte differs
+	c.emit(0x8D, scratch())
+
+	c.placeLabel(routine.loadL)
+	c.emit(0xAE, codeByte(routine.loadL, 0)) // patched
+	c.placeLabel(routine.loadR)
+	c.emit(0xEC, codeByte(routine.loadR, 0)) // patched
+	c.emit(0xD0, labelOperand(differ))
+	// same byte, but was it the end of both? (the high byte of an address is always 0)
+	c.emit(0xEC, codeByte(routine.loadR, 2))
+	c.emit(0xD0, labelOperand(next))
+	c.alwaysBranch(routine.ret)
+
+	c.placeLabel(next)
+	c.emit(0xEE, codeByte(routine.loadL, 1))
+	c.emit(0xEE, codeByte(routine.loadR, 1))
+	c.emit(0xD0, labelOperand(routine.loadL)) // Z is still clear
+
+	c.placeLabel(differ)
+	c.emit(0xA9, immediate(0x00))
+	c.emit(0x8D, scratch())
+	c.placeLabel(routine.ret)
+	c.emit(0xD0, labelOperand(routine.ret)) // patched by the caller
+}
+
+// strings are either literals or string variables
+func (c *Compiler) isString(node *Node) bool {
+	if node.Type != "Token" {
+		return false
+	} else if node.Token.content == "STRING" {
+		return true
+	}
+	return node.Token.tType == Identifier && c.lookupSymbol(node.Token).dataType == "string"
+}
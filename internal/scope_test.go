@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"gopiler/internal/emulator"
+	"strings"
+	"testing"
+)
+
+// sibling blocks declaring the same name each get their own variable, in the scope of their own block
+func TestSiblingScopes(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	c.Compile(`{int a a = 1 {int b b = 2 print(b)} {string c c = "x" print(c)} {boolean b b = true print(b)} print(a)}$`, StageCodeGen)
+	var image *[256]byte = c.GetMemoryImage(0)
+	if image == nil {
+		t.Fatalf("did not compile: %v", c.Diagnostics())
+	}
+	output, err := emulator.New(*image).Run(10000)
+	if err != nil || output != "2x11" {
+		t.Errorf("printed %q (%v), want %q", output, err, "2x11")
+	}
+}
+
+// usage warnings come out in the same order every time
+func TestUsageWarningOrder(t *testing.T) {
+	for run := 0; run < 5; run++ {
+		var c *Compiler = NewCompiler()
+		c.SetVerbose(false)
+		c.SetWebMode(true)
+		c.Compile(`{int d int c int b int a}$`, StageSemantic)
+		var names []string
+		for _, diag := range c.Diagnostics() {
+			if diag.Code == CodeSemNeverInit {
+				names = append(names, strings.Fields(diag.Message)[2])
+			}
+		}
+		if strings.Join(names, " ") != "a b c d" {
+			t.Fatalf("never initialized warnings for %v, want a b c d", names)
+		}
+	}
+}
+
+// a symbol the code generator cannot find is reported like any other error, not a crash
+func TestGenerateReportsUnknownSymbol(t *testing.T) {
+	var c *Compiler = NewCompiler()
+	c.SetVerbose(false)
+	c.SetWebMode(true)
+	c.Compile("{int a a = 1 print(a)}$", StageSemantic)
+
+	var empty *SymbolTableTree = &SymbolTableTree{rootTable: NewSymbolTable("0", nil)}
+	image, diags := c.Generate(&TokenTree{rootNode: c.AstTree(0)}, empty, 0)
+	if image != nil {
+		t.Error("generated an image without the symbols")
+	}
+	if len(diags) == 0 || diags[0].Code != CodeGenUnknownSymbol || diags[0].Severity != SeverityError ||
+		diags[0].Start != (Position{Line: 1, Column: 6}) {
+		t.Errorf("got %v, want a GEN-UNKNOWN-SYMBOL error at the declaration of a", diags)
+	}
+	if strings.Contains(c.GetLogOutput(), "critical error") {
+		t.Errorf("the code generator crashed:\n%s", c.GetLogOutput())
+	}
+}
@@ -3,11 +3,13 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
-var (
-	astList             []TokenTree
+// semantic analyzer state - lives on the Compiler so that compilations do not share it
+type analyzerState struct {
+	astList             []*TokenTree
 	curAst              *TokenTree
 	curParent           *Node
 	parentStack         []*Node // Stack to track parent nodes
@@ -15,18 +17,20 @@ var (
 	symbolTableTreeList []*SymbolTableTree
 	curSymbolTableTree  *SymbolTableTree
 	curSymbolTable      *SymbolTable
-	errorCount          int         = 0
-	warnCount           int         = 0
-	scopeDepth          int         = 0 // just for naming the scopes
-	scopePopulation     map[int]int     // see how many tables at depth for naming
+	errorCount          int
+	warnCount           int
+	scopeDepth          int         // just for naming the scopes
+	scopePopulation     map[int]int // see how many tables at depth for naming
 	astStrings          []string
-	inAssign            bool = false
+	inAssign            bool
 	assignParent        *SymbolEntry
 	assignParentScope   string
-	propagateUsed       map[*SymbolEntry][]*SymbolUsage = make(map[*SymbolEntry][]*SymbolUsage)
+	propagateUsed       map[*SymbolEntry][]*SymbolUsage
 	// used for re-init before use in case self used (earlier deps no longer unused!)
 	dependencyArtifact []*SymbolUsage
-)
+	references         []SymbolRef // every declaration and use found, for editors
+	referenceAt        map[referenceKey]bool
+}
 
 type SymbolUsage struct {
 	symbol *SymbolEntry
@@ -35,13 +39,13 @@ type SymbolUsage struct {
 	pos    int
 }
 
-func populationExists(candidate int) bool {
-	_, exists := scopePopulation[candidate]
+func (c *Compiler) populationExists(candidate int) bool {
+	_, exists := c.scopePopulation[candidate]
 	return exists
 }
 
-func clearStringBuffer() {
-	stringBuffer = []*Node{}
+func (c *Compiler) clearStringBuffer() {
+	c.stringBuffer = []*Node{}
 }
 
 // Garbage tokens to filter out of AST
@@ -49,6 +53,7 @@ var GarbageMap = map[string]struct{}{
 	"KEYW_PRINT":  {},
 	"KEYW_WHILE":  {},
 	"KEYW_IF":     {},
+	"KEYW_ELSE":   {},
 	"OPEN_BRACE":  {},
 	"CLOSE_BRACE": {},
 	"OPEN_PAREN":  {},
@@ -66,201 +71,253 @@ func isGarbage(candidate string) bool {
 	return exists
 }
 
-// entry point
-func SemanticAnalysis(cst TokenTree, programNum int) {
+// BuildAST abstracts a program's CST into its AST
+func (c *Compiler) BuildAST(cst *TokenTree, programNum int) (ast *TokenTree, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
 	defer func() {
 		if r := recover(); r != nil {
-			CriticalError("semantic analyzer", r)
+			c.CriticalError("semantic analyzer", r)
+			ast = nil
 		}
+		diags = c.diagnosticsSince(firstDiag)
 	}()
 
-	Info(fmt.Sprintf("Semantically Analyzing program %d", programNum+1), "GOPILER", true)
+	c.Info(fmt.Sprintf("Semantically Analyzing program %d", programNum+1), "GOPILER", true)
+	c.curProgram = programNum
 
 	// build AST from cst
-	Debug("Generating AST...", "SEMANTIC ANALYZER")
-	initAst(programNum)
-	buildAST(cst)
-	astStrings = append(astStrings, curAst.drawTree())
-	Info(fmt.Sprintf("Program %d Abstract Syntax Tree (AST):\n%s\n%s", programNum+1, strings.Repeat("-", 75),
-		astStrings[programNum]), "GOPILER", true)
+	c.Debug("Generating AST...", "SEMANTIC ANALYZER")
+	c.initAst(programNum)
+	c.buildAST(*cst)
+	c.astStrings[programNum] = c.curAst.drawTree()
+	c.Info(fmt.Sprintf("Program %d Abstract Syntax Tree (AST):\n%s\n%s", programNum+1, strings.Repeat("-", 75),
+		c.astStrings[programNum]), "GOPILER", true)
+	return c.curAst, diags
+}
+
+// Analyze scope and type checks a program's AST, producing its symbol tables.
+// The symbol tables are nil if the program has semantic errors.
+func (c *Compiler) Analyze(ast *TokenTree, programNum int) (symbols *SymbolTableTree, diags []Diagnostic) {
+	var firstDiag int = len(c.diagnostics)
+	defer func() {
+		if r := recover(); r != nil {
+			c.CriticalError("semantic analyzer", r)
+			symbols = nil
+		}
+		diags = c.diagnosticsSince(firstDiag)
+	}()
+
+	c.curProgram = programNum
+	c.curAst = ast
 
 	// perform semantic analysis
-	Debug("Performing Scope and Type checks...", "SEMANTIC ANALYZER")
-	initSymbolTableTree(programNum)
-	scopeTypeCheck(curAst.rootNode) // recursive traversal starting from root
-
-	issueUsageWarnings(curSymbolTableTree.rootTable) // recursive
-	if errorCount == 0 {
-		Pass(fmt.Sprintf("Successfully analyzed program %d with 0 errors and %d warning(s).",
-			programNum+1, warnCount), "SEMANTIC ANALYZER")
-		Info(fmt.Sprintf("Program %d Symbol Table:\n%s\n%s", programNum+1, strings.Repeat("-", 54),
-			curSymbolTableTree.ToString()), "GOPILER", true)
-		CodeGeneration(curAst, curSymbolTableTree, programNum)
+	c.Debug("Performing Scope and Type checks...", "SEMANTIC ANALYZER")
+	c.initSymbolTableTree(programNum)
+	c.scopeTypeCheck(c.curAst.rootNode) // recursive traversal starting from root
+	c.checkIntRanges(c.curAst.rootNode)
+
+	c.issueUsageWarnings(c.curSymbolTableTree.rootTable) // recursive
+	if c.errorCount == 0 {
+		c.Pass(fmt.Sprintf("Successfully analyzed program %d with 0 errors and %d warning(s).",
+			programNum+1, c.warnCount), "SEMANTIC ANALYZER")
+		c.Info(fmt.Sprintf("Program %d Symbol Table:\n%s\n%s", programNum+1, strings.Repeat("-", 54),
+			c.curSymbolTableTree.ToString()), "GOPILER", true)
+		symbols = c.curSymbolTableTree
 	} else {
-		CreateFailedProgramVars(programNum, "semantic")
-		Fail(fmt.Sprintf("Semantic Analysis for program %d failed with %d error(s) and %d warning(s).",
-			programNum+1, errorCount, warnCount), "SEMANTIC ANALYZER")
-		errorMap[programNum] = "semantic"
-		astList[programNum] = TokenTree{}                    // free memory from the AST since it cannot be used
-		symbolTableTreeList[programNum] = &SymbolTableTree{} // free this up too
-		Info(fmt.Sprintf("Compilation of program %d aborted due to semantic analysis error(s).",
+		c.CreateFailedProgramVars(programNum, "semantic")
+		c.Fail(fmt.Sprintf("Semantic Analysis for program %d failed with %d error(s) and %d warning(s).",
+			programNum+1, c.errorCount, c.warnCount), "SEMANTIC ANALYZER")
+		c.errorMap[programNum] = "semantic"
+		// the AST is kept as it is still whole (see Dump), but these tables are missing whatever came after the error
+		c.symbolTableTreeList[programNum] = &SymbolTableTree{}
+		c.Info(fmt.Sprintf("Compilation of program %d aborted due to semantic analysis error(s).",
 			programNum+1), "GOPILER", false)
 	}
 	// reset for next program
-	errorCount = 0
-	warnCount = 0
-	scopeDepth = 0
-	scopePopulation = make(map[int]int)
+	c.errorCount = 0
+	c.warnCount = 0
+	c.scopeDepth = 0
+	c.scopePopulation = make(map[int]int)
+	return symbols, diags
 }
 
 // Initialize AST for a program
-func initAst(pNum int) {
-	for len(astList) <= pNum {
-		astList = append(astList, TokenTree{})
+func (c *Compiler) initAst(pNum int) {
+	for len(c.astList) <= pNum {
+		c.astList = append(c.astList, &TokenTree{})
+		c.astStrings = append(c.astStrings, "")
 	}
-	curAst = &astList[pNum]
+	c.curAst = c.astList[pNum]
 }
 
 // start recursion
-func buildAST(cst TokenTree) {
-	curAst.rootNode = CopyNode(cst.rootNode)
-	curParent = curAst.rootNode
+func (c *Compiler) buildAST(cst TokenTree) {
+	c.curAst.rootNode = CopyNode(cst.rootNode)
+	c.curParent = c.curAst.rootNode
 	// Process children of the root
 	for _, child := range cst.rootNode.Children {
-		extractEssentials(child)
+		c.extractEssentials(child)
 	}
 }
 
 // Recursive AST extraction
-func extractEssentials(node *Node) {
+func (c *Compiler) extractEssentials(node *Node) {
 	// Handle different types of nodes
 	switch node.Type {
 	case "<Block>", "<PrintStatement>", "<AssignmentStatement>", "<VarDecl>",
 		"<WhileStatement>", "<IfStatement>":
-		importantNodeAbstraction(node)
+		c.importantNodeAbstraction(node)
 	case "<IntExpr>":
-		transformIntExpr(node)
+		c.transformIntExpr(node)
 	case "<StringExpr>":
-		transformStringExpr(node)
+		c.transformStringExpr(node)
 	case "<BooleanExpression>":
-		transformBoolExpr(node)
+		c.transformBoolExpr(node)
 	case "Token":
-		transformToken(node)
+		c.transformToken(node)
 	default:
 		// the children of the node are important even if the node itself is not
 		for _, child := range node.Children {
-			extractEssentials(child)
+			c.extractEssentials(child)
 		}
 	}
 }
 
 // Each function call gets its own curParent from the stack, so deeper recursion doesn’t interfere
 // something important that we want an AST node and children for
-func importantNodeAbstraction(node *Node) {
+func (c *Compiler) importantNodeAbstraction(node *Node) {
 	importantNode := NewNode(node.Type, nil)
-	curParent.AddChild(importantNode)
-	Debug(fmt.Sprintf("Added %s to the AST under parent: %s",
-		importantNode.Type, curParent.Type), "SEMANTIC ANALYZER")
+	c.curParent.AddChild(importantNode)
+	c.Debug(fmt.Sprintf("Added %s to the AST under parent: %s",
+		importantNode.Type, c.curParent.Type), "SEMANTIC ANALYZER")
 
 	// Push current parent to stack and update curParent
-	parentStack = append(parentStack, curParent)
-	curParent = importantNode
+	c.parentStack = append(c.parentStack, c.curParent)
+	c.curParent = importantNode
 
 	// Process children
 	for _, child := range node.Children {
-		extractEssentials(child)
+		c.extractEssentials(child)
 	}
 
 	// Restore the previous parent from stack
-	curParent = parentStack[len(parentStack)-1]
-	parentStack = parentStack[:len(parentStack)-1] // Pop the last element
+	c.curParent = c.parentStack[len(c.parentStack)-1]
+	c.parentStack = c.parentStack[:len(c.parentStack)-1] // Pop the last element
 }
 
-// ensure intop (add) becomes the parent left-recursively
+// ensure intop (add, sub) becomes the parent left-recursively
 // we can have boolexprs and string exprs here - is valid for AST
-func transformIntExpr(node *Node) {
+// the grammar nests to the right, but + and - group to the left: 9 - 2 + 3 is (9 - 2) + 3,
+// so each operator takes everything before it as its left side
+func (c *Compiler) transformIntExpr(node *Node) {
 	if len(node.Children) == 1 { // just an int
-		extractEssentials(node.Children[0])
-	} else { // we have an intop! 3 parts - digit intop expr
-		var additionNode *Node = NewNode("<Addition>", nil)
-		curParent.AddChild(additionNode)
-		Debug(fmt.Sprintf("Added <Addition> operation to AST under parent: %s",
-			curParent.Type), "SEMANTIC ANALYZER")
-
-		// Push current parent to stack and update curParent
-		parentStack = append(parentStack, curParent)
-		curParent = additionNode
+		c.extractEssentials(node.Children[0])
+		return
+	}
 
-		// Add the digit as a child
-		curParent.AddChild(CopyNode(node.Children[0].Children[0]))
-		// Process the expression following the operator
-		extractEssentials(node.Children[2])
+	// we have an intop! 3 parts - digit intop expr
+	var left *Node = CopyNode(node.Children[0].Children[0])
+	var opNode *Node
+	for {
+		opNode = NewNode("<Addition>", nil)
+		if node.Children[1].Children[0].Token.content == "SUB" {
+			opNode = NewNode("<Subtraction>", nil)
+		}
+		opNode.AddChild(left)
+		if left.Type != "Token" {
+			c.Debug(fmt.Sprintf("Added %s operation to AST under parent: %s", left.Type, opNode.Type), "SEMANTIC ANALYZER")
+		}
 
-		// Restore the previous parent from stack
-		curParent = parentStack[len(parentStack)-1]
-		parentStack = parentStack[:len(parentStack)-1] // Pop from stack
+		// keep going while the expr after the intop is another digit intop expr
+		var next *Node = node.Children[2].Children[0]
+		if next.Type != "<IntExpr>" || len(next.Children) == 1 {
+			break
+		}
+		opNode.AddChild(CopyNode(next.Children[0].Children[0]))
+		left = opNode
+		node = next
 	}
+
+	c.curParent.AddChild(opNode)
+	c.Debug(fmt.Sprintf("Added %s operation to AST under parent: %s",
+		opNode.Type, c.curParent.Type), "SEMANTIC ANALYZER")
+
+	// Push current parent to stack and update curParent
+	c.parentStack = append(c.parentStack, c.curParent)
+	c.curParent = opNode
+
+	// Process the expression ending the chain
+	c.extractEssentials(node.Children[2])
+
+	// Restore the previous parent from stack
+	c.curParent = c.parentStack[len(c.parentStack)-1]
+	c.parentStack = c.parentStack[:len(c.parentStack)-1] // Pop from stack
 }
 
 // buffer chars and combine them
-func transformStringExpr(node *Node) {
+func (c *Compiler) transformStringExpr(node *Node) {
 	for _, child := range node.Children {
-		extractEssentials(child)
+		c.extractEssentials(child)
 	}
-	var concatNode *Node = collapseCharList()
-	Debug(fmt.Sprintf("Added StringExpr to AST under parent: %s; Collapsed CharList",
-		curParent.Type), "SEMANTIC ANALYZER")
-	curParent.AddChild(concatNode)
+	var concatNode *Node = c.collapseCharList()
+	c.Debug(fmt.Sprintf("Added StringExpr to AST under parent: %s; Collapsed CharList",
+		c.curParent.Type), "SEMANTIC ANALYZER")
+	c.curParent.AddChild(concatNode)
 }
 
 // trust the parser!!! we can hardcode!!
-func transformBoolExpr(node *Node) {
+func (c *Compiler) transformBoolExpr(node *Node) {
 	if len(node.Children) == 1 { // just a boolVal
-		extractEssentials(node.Children[0])
+		c.extractEssentials(node.Children[0])
+	} else if len(node.Children) == 2 { // !, Expr
+		var negationNode *Node = NewNode("<Negation>", nil)
+		c.curParent.AddChild(negationNode)
+		c.Debug(fmt.Sprintf("Added <Negation> to AST under parent: %s", c.curParent.Type), "SEMANTIC ANALYZER")
+
+		c.parentStack = append(c.parentStack, c.curParent)
+		c.curParent = negationNode
+		c.extractEssentials(node.Children[1])
+		c.curParent = c.parentStack[len(c.parentStack)-1]
+		c.parentStack = c.parentStack[:len(c.parentStack)-1]
 	} else {
-		var boolOpNode *Node
-		if node.Children[2].Children[0].Token.content == "EQUAL_OP" {
-			boolOpNode = NewNode("<Equality>", nil)
-		} else { // N-EQUAL_OP
-			boolOpNode = NewNode("<Inequality>", nil)
-		}
+		var boolOpNode *Node = NewNode(boolOps[node.Children[2].Children[0].Token.content], nil)
 
-		curParent.AddChild(boolOpNode)
-		Debug(fmt.Sprintf("Added %s comparison to AST under parent: %s",
-			boolOpNode.Type, curParent.Type), "SEMANTIC ANALYZER")
+		c.curParent.AddChild(boolOpNode)
+		c.Debug(fmt.Sprintf("Added %s operation to AST under parent: %s",
+			boolOpNode.Type, c.curParent.Type), "SEMANTIC ANALYZER")
 
 		// Push current parent to stack and update curParent
-		parentStack = append(parentStack, curParent)
-		curParent = boolOpNode
+		c.parentStack = append(c.parentStack, c.curParent)
+		c.curParent = boolOpNode
 
 		// Process the two expressions
-		extractEssentials(node.Children[1]) // Left expr
-		extractEssentials(node.Children[3]) // Right expr
+		c.extractEssentials(node.Children[1]) // Left expr
+		c.extractEssentials(node.Children[3]) // Right expr
 
 		// Restore the previous parent from stack
-		curParent = parentStack[len(parentStack)-1]
-		parentStack = parentStack[:len(parentStack)-1] // Pop from stack
+		c.curParent = c.parentStack[len(c.parentStack)-1]
+		c.parentStack = c.parentStack[:len(c.parentStack)-1] // Pop from stack
 	}
 }
 
 // individual token
-func transformToken(node *Node) {
+func (c *Compiler) transformToken(node *Node) {
 	var tokenNode *Node = CopyNode(node)
 
 	if node.Type == "Token" && (node.Token.tType == Character || node.Token.content == "QUOTE") {
-		stringBuffer = append(stringBuffer, node)
+		c.stringBuffer = append(c.stringBuffer, node)
 	} else if node.Type == "Token" && !isGarbage(node.Token.content) {
-		curParent.AddChild(tokenNode)
-		Debug(fmt.Sprintf("Added Token [ %s ] to AST under parent: %s",
-			tokenNode.Token.content, curParent.Type), "SEMANTIC ANALYZER")
+		c.curParent.AddChild(tokenNode)
+		c.Debug(fmt.Sprintf("Added Token [ %s ] to AST under parent: %s",
+			tokenNode.Token.content, c.curParent.Type), "SEMANTIC ANALYZER")
 	}
 }
 
 // empty buffer of char nodes into one node w a string value
-func collapseCharList() *Node {
+func (c *Compiler) collapseCharList() *Node {
 	var collapsedStr string = ""
 	// we don't want the quotes, but use them for position data when empty string
-	for _, charNode := range stringBuffer {
+	for _, charNode := range c.stringBuffer {
 		if charNode.Token.content != "QUOTE" {
 			collapsedStr += charNode.Token.trueContent
 		}
@@ -269,160 +326,190 @@ func collapseCharList() *Node {
 	var collapsedCharToken Token = Token{
 		tType: Character,
 		location: Location{ // use the first char for pos data
-			line:     stringBuffer[0].Token.location.line,
-			startPos: stringBuffer[0].Token.location.startPos,
+			line:     c.stringBuffer[0].Token.location.line,
+			startPos: c.stringBuffer[0].Token.location.startPos,
 		},
 		content:     "STRING",
 		trueContent: collapsedStr,
 	}
 
-	clearStringBuffer()
+	c.clearStringBuffer()
 	return NewNode("Token", &collapsedCharToken)
 }
 
-func scopeTypeCheck(node *Node) {
+func (c *Compiler) scopeTypeCheck(node *Node) {
 	switch node.Type {
 	case "<Block>":
-		newDownScope()
+		c.newDownScope()
 		for _, child := range node.Children {
-			scopeTypeCheck(child)
+			c.scopeTypeCheck(child)
 		}
-		goUpScope()
+		c.goUpScope()
 
 	case "<VarDecl>":
-		analyzeVarDecl(node)
+		c.analyzeVarDecl(node)
 	case "<AssignmentStatement>":
-		analyzeAssign(node)
+		c.analyzeAssign(node)
 
 	// intexpr, boolexprs can have type and id issues within
-	case "<Addition>":
-		analyzeAdd(node)
-	case "<Equality>", "<Inequality>":
-		analyzeCompare(node)
+	case "<Addition>", "<Subtraction>":
+		c.analyzeAdd(node)
+	case "<Equality>", "<Inequality>", "<Conjunction>", "<Disjunction>", "<Negation>",
+		"<LessThan>", "<GreaterThan>", "<LessOrEqual>", "<GreaterOrEqual>":
+		c.analyzeCompare(node)
 
 	// print an id
 	case "Token":
 		if node.Token.tType == Identifier {
-			symbol, err := lookup(node.Token.trueContent, node.Token.location)
+			symbol, err := c.lookup(node.Token.trueContent, node.Token.location)
 			if err == nil {
 				if !symbol.isInit {
-					Warn(fmt.Sprintf("Usage of uninitialized symbol [ %s ] in scope [ %s ] at (%d:%d)",
-						symbol.name, curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos), "SEMANTIC ANALYZER")
-					warnCount++
+					c.report(SeverityWarning, StageSemantic, CodeSemUninitialized, node.Token.location, tokenWidth(node.Token),
+						fmt.Sprintf("Usage of uninitialized symbol [ %s ] in scope [ %s ]", symbol.name, c.curSymbolTable.scopeID), "")
+					c.warnCount++
 				}
-				useSymbol(symbol, curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos)
+				c.useSymbol(symbol, c.curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos)
 			}
 		}
 
 	default:
 		for _, child := range node.Children {
-			scopeTypeCheck(child)
+			c.scopeTypeCheck(child)
 		}
 	}
 }
 
-func initSymbolTableTree(pNum int) {
-	for len(symbolTableTreeList) <= pNum {
-		symbolTableTreeList = append(symbolTableTreeList, &SymbolTableTree{})
+func (c *Compiler) initSymbolTableTree(pNum int) {
+	for len(c.symbolTableTreeList) <= pNum {
+		c.symbolTableTreeList = append(c.symbolTableTreeList, &SymbolTableTree{})
 	}
-	curSymbolTableTree = symbolTableTreeList[pNum]
-	scopePopulation = make(map[int]int)
+	c.curSymbolTableTree = c.symbolTableTreeList[pNum]
+	c.scopePopulation = make(map[int]int)
 }
 
-func newDownScope() {
+func (c *Compiler) newDownScope() {
 	// root - has nil parent
-	if curSymbolTableTree.rootTable == nil {
-		curSymbolTableTree.rootTable = NewSymbolTable("0", nil)
-		curSymbolTable = curSymbolTableTree.rootTable
-		scopePopulation[0] = 0
-		Debug(fmt.Sprintf("Encountered <Block>; Created root symbol table scope [ %s ]",
-			curSymbolTable.scopeID), "SEMANTIC ANALYZER")
+	if c.curSymbolTableTree.rootTable == nil {
+		c.curSymbolTableTree.rootTable = NewSymbolTable("0", nil)
+		c.curSymbolTable = c.curSymbolTableTree.rootTable
+		c.scopePopulation[0] = 0
+		c.Debug(fmt.Sprintf("Encountered <Block>; Created root symbol table scope [ %s ]",
+			c.curSymbolTable.scopeID), "SEMANTIC ANALYZER")
 	} else {
 		// not using 1a, 1b bc limit of alpha is 26 possible blocks at certain depth
 		// name will be: depth.table number (ex. third table in scope 1)
 		var newScopeName string
-		if populationExists(scopeDepth) {
-			scopePopulation[scopeDepth] = scopePopulation[scopeDepth] + 1
-			newScopeName = fmt.Sprintf("%d.%d", scopeDepth, scopePopulation[scopeDepth])
+		if c.populationExists(c.scopeDepth) {
+			c.scopePopulation[c.scopeDepth] = c.scopePopulation[c.scopeDepth] + 1
+			newScopeName = fmt.Sprintf("%d.%d", c.scopeDepth, c.scopePopulation[c.scopeDepth])
 		} else {
-			scopePopulation[scopeDepth] = 0
-			newScopeName = fmt.Sprintf("%d.%d", scopeDepth, 0)
+			c.scopePopulation[c.scopeDepth] = 0
+			newScopeName = fmt.Sprintf("%d.%d", c.scopeDepth, 0)
 		}
 
-		var newScope *SymbolTable = NewSymbolTable(newScopeName, curSymbolTable)
-		curSymbolTable.AddSubTable(newScope)
-		curSymbolTable = newScope
-		Debug(fmt.Sprintf("Encountered <Block>; Created new symbol table scope [ %s ] under parent table scope [ %s ]",
-			curSymbolTable.scopeID, curSymbolTable.parentTable.scopeID), "SEMANTIC ANALYZER")
+		var newScope *SymbolTable = NewSymbolTable(newScopeName, c.curSymbolTable)
+		c.curSymbolTable.AddSubTable(newScope)
+		c.curSymbolTable = newScope
+		c.Debug(fmt.Sprintf("Encountered <Block>; Created new symbol table scope [ %s ] under parent table scope [ %s ]",
+			c.curSymbolTable.scopeID, c.curSymbolTable.parentTable.scopeID), "SEMANTIC ANALYZER")
 	}
-	scopeDepth++
+	c.scopeDepth++
 }
 
-func goUpScope() {
+func (c *Compiler) goUpScope() {
 	// if nil, we are at highest scope
-	if curSymbolTable.parentTable != nil {
-		curSymbolTable = curSymbolTable.parentTable
-		scopeDepth--
+	if c.curSymbolTable.parentTable != nil {
+		c.curSymbolTable = c.curSymbolTable.parentTable
+		c.scopeDepth--
 	}
 }
 
-// find an entry in accessible tables, pos is just for err reporting
-func lookup(name string, pos Location) (*SymbolEntry, error) {
-	var searchTable *SymbolTable = curSymbolTable
+// find an entry in accessible tables, pos is for err reporting and references
+func (c *Compiler) lookup(name string, pos Location) (*SymbolEntry, error) {
+	var searchTable *SymbolTable = c.curSymbolTable
 	for {
 		if searchTable.EntryExists(name) {
+			c.addReference(searchTable.entries[name], searchTable.scopeID, pos, false)
 			return searchTable.entries[name], nil
 
 		} else if searchTable.parentTable != nil {
 			searchTable = searchTable.parentTable // look above
 
 		} else {
-			Error(fmt.Sprintf("Undeclared variable (%d:%d): ID [ %s ] was used but not declared",
-				pos.line, pos.startPos, name), "SEMANTIC ANALYZER")
-			errorCount++
+			c.report(SeverityError, StageSemantic, CodeSemUndeclared, pos, len(name),
+				fmt.Sprintf("Undeclared variable: ID [ %s ] was used but not declared", name), "")
+			c.errorCount++
 			return nil, errors.New("symbol not found")
 		}
 	}
 }
 
-func issueUsageWarnings(table *SymbolTable) {
-	for _, entry := range table.entries {
+func (c *Compiler) issueUsageWarnings(table *SymbolTable) {
+	for _, entry := range table.sortedEntries() {
 		if !entry.isInit {
-			Warn(fmt.Sprintf("ID [ %s ] from scope [ %s ] was declared but never initialized.",
-				entry.name, table.scopeID), "SEMANTIC ANALYZER")
-			warnCount++
+			c.report(SeverityWarning, StageSemantic, CodeSemNeverInit, entry.position, len(entry.name),
+				fmt.Sprintf("ID [ %s ] from scope [ %s ] was declared but never initialized", entry.name, table.scopeID), "")
+			c.warnCount++
 		} else if !entry.beenUsed {
-			Warn(fmt.Sprintf("ID [ %s ] from scope [ %s ] was declared and initialized but never used.",
-				entry.name, table.scopeID), "SEMANTIC ANALYZER")
-			warnCount++
+			c.report(SeverityWarning, StageSemantic, CodeSemUnused, entry.position, len(entry.name),
+				fmt.Sprintf("ID [ %s ] from scope [ %s ] was declared and initialized but never used", entry.name, table.scopeID), "")
+			c.warnCount++
 		}
 	}
 
 	for _, subTable := range table.subTables {
-		issueUsageWarnings(subTable)
+		c.issueUsageWarnings(subTable)
 	}
 }
 
-func typeMismatch(operation string, pos Location, leftType string, rightType string) {
-	Error(fmt.Sprintf("Type mismatch on (%d:%d): cannot %s type [ %s ] to type [ %s ]",
-		pos.line, pos.startPos, operation, rightType, leftType), "SEMANTIC ANALYZER")
-	errorCount++
+func (c *Compiler) typeMismatch(operation string, pos Location, leftType string, rightType string) {
+	c.report(SeverityError, StageSemantic, CodeSemTypeMismatch, pos, 1,
+		fmt.Sprintf("Type mismatch: cannot %s type [ %s ] to type [ %s ]", operation, rightType, leftType), "")
+	c.errorCount++
 }
 
-// digit, add -> int
+// and, or, and not only take booleans, and ordering only takes ints
+func (c *Compiler) operatorMismatch(operation string, pos Location, operandType string) {
+	var symbols map[string]string = map[string]string{"<Conjunction>": "&&", "<Disjunction>": "||", "<Negation>": "!",
+		"<LessThan>": "<", "<GreaterThan>": ">", "<LessOrEqual>": "<=", "<GreaterOrEqual>": ">="}
+	c.report(SeverityError, StageSemantic, CodeSemTypeMismatch, pos, 1,
+		fmt.Sprintf("Type mismatch: cannot apply [ %s ] to type [ %s ]", symbols[operation], operandType), "")
+	c.errorCount++
+}
+
+// everything with a boolean result
+func isBoolOp(node *Node) bool {
+	switch node.Type {
+	case "<Equality>", "<Inequality>", "<Conjunction>", "<Disjunction>", "<Negation>",
+		"<LessThan>", "<GreaterThan>", "<LessOrEqual>", "<GreaterOrEqual>":
+		return true
+	}
+	return false
+}
+
+// <, >, <= and >=
+func isOrdering(node *Node) bool {
+	switch node.Type {
+	case "<LessThan>", "<GreaterThan>", "<LessOrEqual>", "<GreaterOrEqual>":
+		return true
+	}
+	return false
+}
+
+// digit, add, sub -> int
 // string -> string
-// equality, boolval -> bool
+// equality, ordering, logic, boolval -> bool
 // id -> type of symbol
-func getNodeType(node *Node, examineChildren bool, markUsed bool) string {
-	if node.Type == "<Addition>" {
+func (c *Compiler) getNodeType(node *Node, examineChildren bool, markUsed bool) string {
+	if node.Type == "<Addition>" || node.Type == "<Subtraction>" {
 		if examineChildren {
-			analyzeAdd(node)
+			c.analyzeAdd(node)
 		}
 		return "int"
 
-	} else if node.Type == "<Equality>" || node.Type == "<Inequality>" {
+	} else if isBoolOp(node) {
 		if examineChildren {
-			analyzeCompare(node)
+			c.analyzeCompare(node)
 		}
 		return "boolean"
 
@@ -434,36 +521,37 @@ func getNodeType(node *Node, examineChildren bool, markUsed bool) string {
 			return "string"
 
 		} else if node.Token.tType == Identifier {
-			symbol, err := lookup(node.Token.trueContent, node.Token.location)
+			symbol, err := c.lookup(node.Token.trueContent, node.Token.location)
 			if err != nil {
 				return "" // id doesn't exist - bail
 			}
 
 			if markUsed {
 				if !symbol.isInit {
-					Warn(fmt.Sprintf("Usage of uninitialized symbol [ %s ] in scope [ %s ] at (%d:%d). Default value will be inferred based on type!",
-						symbol.name, curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos), "SEMANTIC ANALYZER")
-					warnCount++
+					c.report(SeverityWarning, StageSemantic, CodeSemUninitialized, node.Token.location, tokenWidth(node.Token),
+						fmt.Sprintf("Usage of uninitialized symbol [ %s ] in scope [ %s ]", symbol.name, c.curSymbolTable.scopeID),
+						"Default value will be inferred based on type!")
+					c.warnCount++
 				}
 
 				// part of an assignment, its usage depends on usage of var its being used to assign
-				if inAssign {
+				if c.inAssign {
 					//
-					if symbol == assignParent {
-						Debug(fmt.Sprintf("Reinstated dependency artifacts on symbol [ %s ] in scope [ %s ] at (%d:%d) due to self-assignment",
-							assignParent.name, assignParentScope, node.Token.location.line, node.Token.location.startPos), "SEMANTIC ANALYZER")
-						propagateUsed[assignParent] = append(propagateUsed[assignParent],
-							dependencyArtifact...)
+					if symbol == c.assignParent {
+						c.Debug(fmt.Sprintf("Reinstated dependency artifacts on symbol [ %s ] in scope [ %s ] at (%d:%d) due to self-assignment",
+							c.assignParent.name, c.assignParentScope, node.Token.location.line, node.Token.location.startPos), "SEMANTIC ANALYZER")
+						c.propagateUsed[c.assignParent] = append(c.propagateUsed[c.assignParent],
+							c.dependencyArtifact...)
 
 					} else {
-						Debug(fmt.Sprintf("Created usage dependency on symbol [ %s ] in scope [ %s ] for symbol [ %s ] in scope [ %s ] at (%d:%d)",
-							assignParent.name, assignParentScope, symbol.name, curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos), "SEMANTIC ANALYZER")
-						propagateUsed[assignParent] = append(propagateUsed[assignParent],
-							&SymbolUsage{symbol, curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos})
+						c.Debug(fmt.Sprintf("Created usage dependency on symbol [ %s ] in scope [ %s ] for symbol [ %s ] in scope [ %s ] at (%d:%d)",
+							c.assignParent.name, c.assignParentScope, symbol.name, c.curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos), "SEMANTIC ANALYZER")
+						c.propagateUsed[c.assignParent] = append(c.propagateUsed[c.assignParent],
+							&SymbolUsage{symbol, c.curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos})
 					}
 
 				} else {
-					useSymbol(symbol, curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos)
+					c.useSymbol(symbol, c.curSymbolTable.scopeID, node.Token.location.line, node.Token.location.startPos)
 				}
 			}
 			return symbol.dataType
@@ -479,103 +567,147 @@ func getNodeType(node *Node, examineChildren bool, markUsed bool) string {
 
 // new symbol
 // children of varDecl: type id
-func analyzeVarDecl(node *Node) {
+func (c *Compiler) analyzeVarDecl(node *Node) {
 	var name string = node.Children[1].Token.trueContent
 	var pos Location = node.Children[1].Token.location
 
-	if curSymbolTable.EntryExists(name) {
+	if c.curSymbolTable.EntryExists(name) {
 		// id already used in this scope
-		Error(fmt.Sprintf("Declaration Error on (%d:%d): ID [ %s ] is already declared in scope [ %s ].",
-			pos.line, pos.startPos, name, curSymbolTable.scopeID), "SEMANTIC ANALYZER")
-		errorCount++
+		c.report(SeverityError, StageSemantic, CodeSemRedeclared, pos, len(name),
+			fmt.Sprintf("Declaration Error: ID [ %s ] is already declared in scope [ %s ]", name, c.curSymbolTable.scopeID), "")
+		c.errorCount++
 	} else {
 		var dType string = node.Children[0].Token.trueContent
 		var entry *SymbolEntry = NewTableEntry(name, dType, pos)
-		curSymbolTable.AddEntry(name, entry)
-		Debug(fmt.Sprintf("Declared new entry [ %s ] of type [ %s ] in scope [ %s ] at (%d:%d)",
-			name, dType, curSymbolTable.scopeID, pos.line, pos.startPos), "SEMANTIC ANALYZER")
+		c.curSymbolTable.AddEntry(name, entry)
+		c.addReference(entry, c.curSymbolTable.scopeID, pos, true)
+		c.Debug(fmt.Sprintf("Declared new entry [ %s ] of type [ %s ] in scope [ %s ] at (%d:%d)",
+			name, dType, c.curSymbolTable.scopeID, pos.line, pos.startPos), "SEMANTIC ANALYZER")
 	}
 }
 
-func analyzeAssign(node *Node) {
-	inAssign = true
+func (c *Compiler) analyzeAssign(node *Node) {
+	c.inAssign = true
 	var assigneeNode *Node = node.Children[0]
-	assignee, err := lookup(assigneeNode.Token.trueContent, node.Children[0].Token.location)
+	assignee, err := c.lookup(assigneeNode.Token.trueContent, node.Children[0].Token.location)
 	// assignee does not exist, we are done here
 	if err != nil {
 		return
 	}
-	assignParent = assignee
-	assignParentScope = curSymbolTable.scopeID
-	artifact, ok := propagateUsed[assignParent]
+	c.assignParent = assignee
+	c.assignParentScope = c.curSymbolTable.scopeID
+	artifact, ok := c.propagateUsed[c.assignParent]
 	if ok { // store old decl from map in case it is needed
-		dependencyArtifact = artifact
+		c.dependencyArtifact = artifact
 	}
-	propagateUsed[assignParent] = []*SymbolUsage{}
+	c.propagateUsed[c.assignParent] = []*SymbolUsage{}
 
 	var assignTo *Node = node.Children[1]
-	var assignToType string = getNodeType(assignTo, true, true)
+	var assignToType string = c.getNodeType(assignTo, true, true)
 
 	if assignToType == "" {
 		return // bad ID - go no further
 	} else if assignee.dataType != assignToType {
-		typeMismatch("assign", assigneeNode.Token.location, assignee.dataType, assignToType)
+		c.typeMismatch("assign", assigneeNode.Token.location, assignee.dataType, assignToType)
 	} else {
-		Debug(fmt.Sprintf("Type checked assignment of entry [ %s ] in scope [ %s ] at (%d:%d)",
-			assignee.name, curSymbolTable.scopeID, assignee.position.line, assignee.position.startPos), "SEMANTIC ANALYZER")
+		c.Debug(fmt.Sprintf("Type checked assignment of entry [ %s ] in scope [ %s ] at (%d:%d)",
+			assignee.name, c.curSymbolTable.scopeID, assignee.position.line, assignee.position.startPos), "SEMANTIC ANALYZER")
 		if !assignee.isInit {
 			assignee.isInit = true
-			Debug(fmt.Sprintf("Initialized entry [ %s ] in scope [ %s ] at (%d:%d)",
-				assignee.name, curSymbolTable.scopeID, assignee.position.line, assignee.position.startPos), "SEMANTIC ANALYZER")
+			c.Debug(fmt.Sprintf("Initialized entry [ %s ] in scope [ %s ] at (%d:%d)",
+				assignee.name, c.curSymbolTable.scopeID, assignee.position.line, assignee.position.startPos), "SEMANTIC ANALYZER")
 		}
 	}
-	inAssign = false
+	c.inAssign = false
 }
 
-// easier bc no valid subexpressions that aren't add
+// easier bc no valid subexpressions that aren't add or sub
 // we don't even need to check the left side of intop because parser did (digit)
-// right can be add, string, digit, id, bool, equality
-func analyzeAdd(node *Node) {
-	var leftAdd *Node = node.Children[0] // always a digit!!
+// right can be add, sub, string, digit, id, bool, equality
+func (c *Compiler) analyzeAdd(node *Node) {
+	var leftAdd *Node = node.Children[0] // a digit, or the operations before this one
 	var rightAdd *Node = node.Children[1]
-	var rightAddType string = getNodeType(rightAdd, true, true)
+	var loc Location // of the digit right before the operator
+	if leftAdd.Type == "Token" {
+		loc = leftAdd.Token.location
+	} else {
+		c.analyzeAdd(leftAdd)
+		loc = leftAdd.Children[1].Token.location // earlier operations end in a digit
+	}
+	var rightAddType string = c.getNodeType(rightAdd, true, true)
 
 	if rightAddType == "" {
 		return // bad ID - go no further
 	} else if rightAddType != "int" {
-		typeMismatch("compare", leftAdd.Token.location, "int", rightAddType)
+		c.typeMismatch("compare", loc, "int", rightAddType)
 	} else {
-		Debug(fmt.Sprintf("Type checked <Addition> at (%d:%d)",
-			leftAdd.Token.location.line, leftAdd.Token.location.startPos), "SEMANTIC ANALYZER")
+		c.Debug(fmt.Sprintf("Type checked %s at (%d:%d)", node.Type, loc.line, loc.startPos), "SEMANTIC ANALYZER")
 	}
 }
 
 // -boolop
 // --expr
 // --expr
-func analyzeCompare(node *Node) {
+// (negation has the one expr)
+func (c *Compiler) analyzeCompare(node *Node) {
+	if node.Type == "<Negation>" {
+		var operandType string = c.getNodeType(node.Children[0], true, true)
+		if operandType != "" && operandType != "boolean" {
+			c.operatorMismatch(node.Type, firstToken(node.Children[0]).location, operandType)
+		}
+		return
+	}
+
 	// the types of these must match
 	var leftCompare *Node = node.Children[0]
-	var leftType string = getNodeType(leftCompare, true, true)
+	var leftType string = c.getNodeType(leftCompare, true, true)
 	var rightCompare *Node = node.Children[1]
-	var rightType string = getNodeType(rightCompare, true, true)
+	var rightType string = c.getNodeType(rightCompare, true, true)
 
 	if leftType == "" || rightType == "" {
 		return // bad ID - go no further
+	} else if node.Type == "<Conjunction>" || node.Type == "<Disjunction>" {
+		if leftType != "boolean" {
+			c.operatorMismatch(node.Type, firstToken(leftCompare).location, leftType)
+		}
+		if rightType != "boolean" {
+			c.operatorMismatch(node.Type, firstToken(rightCompare).location, rightType)
+		}
+	} else if isOrdering(node) {
+		if leftType != "int" {
+			c.operatorMismatch(node.Type, firstToken(leftCompare).location, leftType)
+		}
+		if rightType != "int" {
+			c.operatorMismatch(node.Type, firstToken(rightCompare).location, rightType)
+		}
 	} else if leftType != rightType {
-		typeMismatch("compare", leftCompare.Token.location, leftType, rightType)
+		c.typeMismatch("compare", firstToken(leftCompare).location, leftType, rightType)
 	} else {
-		Debug(fmt.Sprintf("Type checked %s", node.Type), "SEMANTIC ANALYZER")
+		c.Debug(fmt.Sprintf("Type checked %s", node.Type), "SEMANTIC ANALYZER")
+	}
+}
+
+// ints are a byte, so literals have to fit in one
+func (c *Compiler) checkIntRanges(node *Node) {
+	if node.Type == "Token" && node.Token.tType == Digit {
+		if value, err := strconv.Atoi(node.Token.trueContent); err != nil || value > 255 {
+			c.report(SeverityError, StageSemantic, CodeSemIntRange, node.Token.location, tokenWidth(node.Token),
+				fmt.Sprintf("Integer literal [ %s ] is out of range", node.Token.trueContent), "Integers are 8 bits: 0 to 255.")
+			c.errorCount++
+		}
+	}
+	for _, child := range node.Children {
+		c.checkIntRanges(child)
 	}
 }
 
 // propagate usage to dependents
-func useSymbol(sym *SymbolEntry, scope string, line int, pos int) {
+func (c *Compiler) useSymbol(sym *SymbolEntry, scope string, line int, pos int) {
 	visited := make(map[*SymbolEntry]bool)
-	useSymbolHelper(sym, scope, line, pos, visited)
+	c.useSymbolHelper(sym, scope, line, pos, visited)
 }
 
-func useSymbolHelper(sym *SymbolEntry, scope string, line int, pos int, visited map[*SymbolEntry]bool) {
+func (c *Compiler) useSymbolHelper(sym *SymbolEntry, scope string, line int, pos int, visited map[*SymbolEntry]bool) {
 	// If we've already visited this symbol, don't recurse
 	if visited[sym] {
 		return
@@ -583,46 +715,46 @@ func useSymbolHelper(sym *SymbolEntry, scope string, line int, pos int, visited
 	visited[sym] = true
 
 	sym.beenUsed = true
-	Debug(fmt.Sprintf("Used entry [ %s ] in scope [ %s ] at (%d:%d)",
+	c.Debug(fmt.Sprintf("Used entry [ %s ] in scope [ %s ] at (%d:%d)",
 		sym.name, scope, line, pos), "SEMANTIC ANALYZER")
 
-	for _, dependency := range propagateUsed[sym] {
-		useSymbolHelper(dependency.symbol, dependency.scope, dependency.line, dependency.pos, visited)
+	for _, dependency := range c.propagateUsed[sym] {
+		c.useSymbolHelper(dependency.symbol, dependency.scope, dependency.line, dependency.pos, visited)
 	}
 
-	delete(propagateUsed, sym)
+	delete(c.propagateUsed, sym)
 }
 
-func GetAst() string {
-	if len(astStrings) == 0 {
+func (c *Compiler) GetAst() string {
+	if len(c.astStrings) == 0 {
 		return fmt.Sprintf("Program 1\n%s\nNo AST generated due to %s error\n\n",
-			strings.Repeat("-", 75), errorMap[0])
+			strings.Repeat("-", 75), c.errorMap[0])
 	}
 	var astString string = ""
-	for i, ast := range astStrings {
+	for i, ast := range c.astStrings {
 		astString += fmt.Sprintf("Program %d\n%s", i+1, strings.Repeat("-", 75))
-		if !hadError(i) || (errorMap[i] != "parser" && errorMap[i] != "lexer") {
+		if !c.hadError(i) || (c.errorMap[i] != "parser" && c.errorMap[i] != "lexer") {
 			astString += ast + "\n"
 		} else {
-			astString += fmt.Sprintf("\nNo AST generated due to %s error\n\n", errorMap[i])
+			astString += fmt.Sprintf("\nNo AST generated due to %s error\n\n", c.errorMap[i])
 		}
 	}
 	return astString
 }
 
-func GetSymbolTables() string {
-	if len(symbolTableTreeList) == 0 {
+func (c *Compiler) GetSymbolTables() string {
+	if len(c.symbolTableTreeList) == 0 {
 		return fmt.Sprintf("<b>Program 1</b><p>No symbol tables generated due to %s error</p><br></br>",
-			errorMap[0])
+			c.errorMap[0])
 	}
 
 	var tablesHtml string = ""
-	for i, tbl := range symbolTableTreeList {
+	for i, tbl := range c.symbolTableTreeList {
 		tablesHtml += fmt.Sprintf("<b>Program %d</b>", i+1)
-		if !hadError(i) || (errorMap[i] != "semantic" && errorMap[i] != "parser" && errorMap[i] != "lexer") {
+		if !c.hadError(i) || (c.errorMap[i] != "semantic" && c.errorMap[i] != "parser" && c.errorMap[i] != "lexer") {
 			tablesHtml += tbl.ToHtmlTable() + "<br></br>"
 		} else {
-			tablesHtml += fmt.Sprintf("<p>No symbol tables generated due to %s error</p><br></br>", errorMap[i])
+			tablesHtml += fmt.Sprintf("<p>No symbol tables generated due to %s error</p><br></br>", c.errorMap[i])
 		}
 	}
 	return tablesHtml
@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* Interactive sessions.
+A Session is a program built up an entry at a time, for the REPL. Entries go inside an
+implicit block, so the passes still get a whole program: every entry so far is compiled
+together, each on its own lines, and an entry is only kept if that still compiles and runs.
+That keeps declarations (and the symbol table) around for later entries, and since the
+emulator starts fresh each time, what the entry printed is whatever comes after the
+output of the entries before it.
+This replay has limits: every entry runs all the earlier ones again, so loops in them count
+against sessionMaxSteps each time, and the whole session has to fit in 256 bytes.
+Programs have no input, so a replay prints what it did before; if it ever does not,
+the entry is rejected instead of guessing what it printed.
+Positions handed out are relative to an entry: a line of the entry, and for symbols, which entry. */
+
+const sessionMaxSteps int = 100000
+
+type Session struct {
+	entries  []string
+	compiler *Compiler // the accepted entries, compiled
+	output   string    // what they printed
+}
+
+func NewSession() *Session {
+	var s *Session = &Session{}
+	s.Reset()
+	return s
+}
+
+// Reset forgets every entry
+func (s *Session) Reset() {
+	s.entries = nil
+	s.compiler, s.output, _ = compileSession(nil)
+}
+
+// the program the entries make, each starting on its own line after the {
+func sessionSource(entries []string) string {
+	return "{\n" + strings.Join(entries, "\n") + "\n}$"
+}
+
+// first line of each entry in sessionSource
+func entryLine(entries []string, entry int) int {
+	var line int = 2
+	for _, e := range entries[:entry] {
+		line += strings.Count(e, "\n") + 1
+	}
+	return line
+}
+
+func compileSession(entries []string) (*Compiler, string, []Diagnostic) {
+	var c *Compiler = NewCompiler()
+	c.SetWebMode(true) // the logs are not for the user
+	c.SetVerbose(false)
+	c.SetOptimize(true) // the whole session shares 256 bytes
+	c.Compile(sessionSource(entries), StageCodeGen)
+	if c.GetMemoryImage(0) == nil {
+		return c, "", c.Diagnostics()
+	}
+	output, _ := c.Run(0, sessionMaxSteps) // its diagnostics are recorded with the rest
+	return c, output, c.Diagnostics()
+}
+
+// Enter adds one or more statements to the session. They are kept only if the session still
+// compiles and runs, and printed is what they printed.
+// Diagnostics are errors anywhere (there should be none but the entry's) and the entry's
+// own warnings, with lines counted from the start of the entry.
+func (s *Session) Enter(entry string) (printed string, diags []Diagnostic, accepted bool) {
+	if strings.Contains(entry, "$") {
+		return "", []Diagnostic{{Severity: SeverityError, Stage: StageLexer, Code: CodeLexInvalidChar,
+			Message: "Entries are inside an implicit block that is never ended, so [ $ ] is not allowed"}}, false
+	}
+
+	var candidate []string = append(append([]string{}, s.entries...), entry)
+	compiler, output, all := compileSession(candidate)
+	var first int = entryLine(candidate, len(s.entries))
+	var last int = first + strings.Count(entry, "\n")
+
+	accepted = true
+	for _, diag := range all {
+		var inEntry bool = diag.Start.Line >= first && diag.Start.Line <= last
+		if diag.Severity == SeverityError {
+			accepted = false
+		} else if !inEntry || diag.Code == CodeSemUnused || diag.Code == CodeSemNeverInit {
+			continue // later entries may well use it
+		}
+		if inEntry {
+			diag.Start.Line -= first - 1
+			diag.End.Line -= first - 1
+		} else {
+			diag.Start, diag.End = Position{}, Position{}
+		}
+		diags = append(diags, diag)
+	}
+	if !accepted {
+		return "", diags, false
+	}
+
+	if !strings.HasPrefix(output, s.output) {
+		return "", append(diags, Diagnostic{Severity: SeverityError, Stage: StageRuntime, Code: CodeRunFault,
+			Message: fmt.Sprintf("Running the session again printed %q before this entry instead of %q", output, s.output)}), false
+	}
+
+	s.entries = candidate
+	s.compiler = compiler
+	printed = output[len(s.output):]
+	s.output = output
+	return printed, diags, true
+}
+
+// which entry a line of sessionSource is in, and its line in that entry (both from 1)
+func (s *Session) entryAt(line int) (entry int, entryLine int) {
+	var first int = 2
+	for i, e := range s.entries {
+		var lines int = strings.Count(e, "\n") + 1
+		if line < first+lines {
+			return i + 1, line - first + 1
+		}
+		first += lines
+	}
+	return 0, 0
+}
+
+// EntryIsOpen reports whether an entry has a { that is not closed yet, or ends inside a comment,
+// so the REPL should read another line for it. Braces in comments and strings do not count.
+func EntryIsOpen(entry string) bool {
+	var depth int = 0
+	var inString bool = false
+	for i := 0; i < len(entry); i++ {
+		switch {
+		case !inString && strings.HasPrefix(entry[i:], "/*"):
+			var end int = strings.Index(entry[i+2:], "*/")
+			if end == -1 {
+				return true
+			}
+			i += end + 3 // land on the '/' of */
+		case entry[i] == '"':
+			inString = !inString
+		case entry[i] == '\n':
+			inString = false // strings cannot span lines
+		case !inString && entry[i] == '{':
+			depth++
+		case !inString && entry[i] == '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// the tokens of the last entry, a line each
+func (s *Session) Tokens() string {
+	if len(s.entries) == 0 {
+		return ""
+	}
+	var first int = entryLine(s.entries, len(s.entries)-1)
+	var sb strings.Builder
+	for _, token := range s.compiler.TokenStream(0) {
+		if token.location.line >= first && token.content != "EOP" && !(token.content == "CLOSE_BRACE" &&
+			token.location.line > first+strings.Count(s.entries[len(s.entries)-1], "\n")) {
+			sb.WriteString(fmt.Sprintf("%s [ %s ] at (%d:%d)\n", token.content, token.trueContent,
+				token.location.line-first+1, token.location.startPos))
+		}
+	}
+	return sb.String()
+}
+
+// the session's AST as the dash indented tree
+func (s *Session) AST() string {
+	var sb strings.Builder
+	if root := s.compiler.AstTree(0); root != nil {
+		root.PrintNode(&sb, 0)
+	}
+	return sb.String()
+}
+
+// the session's symbol table, with the entry each symbol was declared in
+func (s *Session) Symbols() string {
+	if len(s.compiler.symbolTableTreeList) == 0 || s.compiler.symbolTableTreeList[0].rootTable == nil {
+		return ""
+	}
+	var root *SymbolTable = s.compiler.symbolTableTreeList[0].rootTable
+	if root.IsEmpty() {
+		return "The session does not contain any symbols."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("| %-5s | %-4s | %-7s | %-5s | %-8s | %-5s | %-5s |\n",
+		"Scope", "Name", "Type", "Entry", "Position", "Init?", "Used?"))
+	sb.WriteString(strings.Repeat("-", 63) + "\n")
+	s.collectSymbols(root, &sb)
+	return sb.String()
+}
+
+func (s *Session) collectSymbols(table *SymbolTable, sb *strings.Builder) {
+	for _, symbol := range table.sortedEntries() {
+		entry, line := s.entryAt(symbol.position.line)
+		var pos string = fmt.Sprintf("(%d:%d)", line, symbol.position.startPos)
+		sb.WriteString(fmt.Sprintf("| %-5s | %-4s | %-7s | %-5d | %-8s | %-5t | %-5t |\n",
+			table.scopeID, symbol.name, symbol.dataType, entry, pos, symbol.isInit, symbol.beenUsed))
+		sb.WriteString(strings.Repeat("-", 63) + "\n")
+	}
+	for _, subTable := range table.subTables {
+		s.collectSymbols(subTable, sb)
+	}
+}
+
+// the session's assembly, an instruction a line
+func (s *Session) Assembly() string {
+	return strings.Join(s.compiler.AssemblyLines(0), "\n") + "\n"
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSessionKeepsState(t *testing.T) {
+	var s *Session = NewSession()
+	var steps = []struct {
+		entry    string
+		printed  string
+		accepted bool
+	}{
+		{"int a", "", true},
+		{"a = 3", "", true},
+		{"print(a)", "3", true},
+		{"print(b)", "", false}, // undeclared, so forgotten
+		{"string a", "", false}, // redeclared
+		{"while (a != 5) {\n    a = 1 + a\n    print(a)\n}", "45", true},
+		{"print(\"done\")", "done", true},
+	}
+	for _, step := range steps {
+		printed, diags, accepted := s.Enter(step.entry)
+		if accepted != step.accepted || printed != step.printed {
+			t.Errorf("%q: printed %q accepted %v, want %q %v (diagnostics %v)",
+				step.entry, printed, accepted, step.printed, step.accepted, diags)
+		}
+	}
+	if !strings.Contains(s.Symbols(), "| a ") || strings.Contains(s.Symbols(), "| b ") {
+		t.Errorf("symbol table should only have a:\n%s", s.Symbols())
+	}
+	if !strings.HasPrefix(s.Tokens(), "KEYW_PRINT [ print ] at (1:1)") {
+		t.Errorf(":tokens should be the last entry's, counted from its start:\n%s", s.Tokens())
+	}
+
+	s.Reset()
+	if _, _, accepted := s.Enter("print(a)"); accepted {
+		t.Error("a was still declared after Reset")
+	}
+}
+
+// diagnostics point into the entry, and warnings later entries may fix are left out
+func TestSessionDiagnostics(t *testing.T) {
+	var s *Session = NewSession()
+	s.Enter("int a")
+	s.Enter("int b")
+	_, diags, _ := s.Enter("\nb = \"x\"")
+	if len(diags) != 1 || diags[0].Code != CodeSemTypeMismatch || diags[0].Start.Line != 2 {
+		t.Errorf("want one type mismatch on line 2 of the entry, got %v", diags)
+	}
+	if _, diags, accepted := s.Enter("a = 1"); !accepted || len(diags) != 0 {
+		t.Errorf("an unused a should not be reported yet, got %v", diags)
+	}
+	if _, _, accepted := s.Enter("}$"); accepted {
+		t.Error("an entry ending the program was accepted")
+	}
+}
+
+// symbols say which entry they were declared in and where in it
+func TestSessionSymbols(t *testing.T) {
+	var s *Session = NewSession()
+	s.Enter("int a")
+	s.Enter("a = 1\n  string b")
+	var symbols string = s.Symbols()
+	if !strings.Contains(symbols, "| 0     | a    | int     | 1     | (1:5)") ||
+		!strings.Contains(symbols, "| 0     | b    | string  | 2     | (2:10)") {
+		t.Errorf("want a at 1:5 of entry 1 and b at 2:10 of entry 2:\n%s", symbols)
+	}
+}
+
+func TestEntryIsOpen(t *testing.T) {
+	for entry, open := range map[string]bool{
+		"print(1)":                    false,
+		"while true {":                true,
+		"while true {\n  print(1)\n}": false,
+		"print(1) /* { */":            false,
+		"print(\"{\")":                false,
+		"if true { /* } */":           true,
+		"print(1) /* unfinished":      true,
+		"{ print(\"}\") }":            false,
+	} {
+		if EntryIsOpen(entry) != open {
+			t.Errorf("EntryIsOpen(%q) = %v, want %v", entry, !open, open)
+		}
+	}
+}
@@ -84,12 +84,9 @@ func (stt *SymbolTableTree) ToString() string {
 	return sb.String()
 }
 
-func (table *SymbolTable) collectEntries(sb *strings.Builder) {
-	if table == nil {
-		return
-	}
-
-	// Convert map to slice for sorting - for my eyeballs to see alphabetized
+// entries alphabetized by name - maps have no order and output should be the same every run
+func (table *SymbolTable) sortedEntries() []*SymbolEntry {
+	// Convert map to slice for sorting
 	entrySlice := make([]*SymbolEntry, 0, len(table.entries))
 	for _, entry := range table.entries {
 		entrySlice = append(entrySlice, entry)
@@ -99,8 +96,15 @@ func (table *SymbolTable) collectEntries(sb *strings.Builder) {
 	sort.Slice(entrySlice, func(i, j int) bool {
 		return entrySlice[i].name < entrySlice[j].name
 	})
+	return entrySlice
+}
+
+func (table *SymbolTable) collectEntries(sb *strings.Builder) {
+	if table == nil {
+		return
+	}
 
-	for _, entry := range entrySlice {
+	for _, entry := range table.sortedEntries() {
 		var pos string = fmt.Sprintf("(%d:%d)", entry.position.line, entry.position.startPos)
 		sb.WriteString(fmt.Sprintf("| %-5s | %-4s | %-7s | %-9s | %-5t | %-5t |\n",
 			table.scopeID, entry.name, entry.dataType, pos, entry.isInit, entry.beenUsed))
@@ -141,18 +145,7 @@ func (table *SymbolTable) collectEntriesHtml(sb *strings.Builder) {
 		return
 	}
 
-	// Convert map to slice for sorting - for better readability
-	entrySlice := make([]*SymbolEntry, 0, len(table.entries))
-	for _, entry := range table.entries {
-		entrySlice = append(entrySlice, entry)
-	}
-
-	// Sort slice by name ASC
-	sort.Slice(entrySlice, func(i, j int) bool {
-		return entrySlice[i].name < entrySlice[j].name
-	})
-
-	for _, entry := range entrySlice {
+	for _, entry := range table.sortedEntries() {
 		pos := fmt.Sprintf("(%d:%d)", entry.position.line, entry.position.startPos)
 		sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%t</td><td>%t</td></tr>\n",
 			table.scopeID, entry.name, entry.dataType, pos, entry.isInit, entry.beenUsed))
@@ -36,6 +36,9 @@ var SymbolMap = map[rune]string{
 	'=': "ASSIGN_OP",
 	'"': "QUOTE",
 	'+': "ADD",
+	'-': "SUB",
+	'<': "LESS_OP",
+	'>': "GREATER_OP",
 }
 
 var TypeMap = map[string]string{
@@ -1,6 +1,9 @@
 package internal
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 /* I know that I could just import a tree to use for this project.
 Why not use my DSA skills to make my own?
@@ -9,8 +12,6 @@ Go does not have classes. However, you can define methods on types.
 A method is a function with a special receiver argument.
 The receiver appears in its own argument list between the func keyword and the method name. */
 
-var printTreeBuffer string // used to build str rep of tree
-
 // capitalize its fields for global access
 type Node struct {
 	Type     string // token (terminals), or name of parse block (expr, IfStatement, etc)
@@ -41,32 +42,45 @@ func CopyNode(original *Node) *Node {
 	return newNode
 }
 
+// copies the node and everything under it (tokens are still shared)
+func CopyTree(original *Node) *Node {
+	var newNode *Node = CopyNode(original)
+	if newNode == nil {
+		return nil
+	}
+	for _, child := range original.Children {
+		newNode.AddChild(CopyTree(child))
+	}
+	return newNode
+}
+
 func (node *Node) AddChild(newChild *Node) {
 	node.Children = append(node.Children, newChild)
 }
 
-func (node *Node) PrintNode(level int) {
+// writes the str rep of the tree into sb
+func (node *Node) PrintNode(sb *strings.Builder, level int) {
 	for i := 0; i < level; i++ {
-		printTreeBuffer += "-"
+		sb.WriteString("-")
 	}
 
 	if node.Token != nil {
 		if node.Token.trueContent == " " { // we have a token
-			printTreeBuffer += fmt.Sprintf("{%s [ space ]}\n", node.Token.content)
+			sb.WriteString(fmt.Sprintf("{%s [ space ]}\n", node.Token.content))
 		} else {
-			printTreeBuffer += fmt.Sprintf("{%s [ %s ]}\n", node.Token.content, node.Token.trueContent)
+			sb.WriteString(fmt.Sprintf("{%s [ %s ]}\n", node.Token.content, node.Token.trueContent))
 		}
 	} else {
-		printTreeBuffer += node.Type + "\n" // non terminal
+		sb.WriteString(node.Type + "\n") // non terminal
 	}
 
 	for _, child := range node.Children {
-		child.PrintNode(level + 1) // Recursively print children
+		child.PrintNode(sb, level+1) // Recursively print children
 	}
 }
 
 func (tree *TokenTree) drawTree() string {
-	printTreeBuffer = ""
-	tree.rootNode.PrintNode(0)
-	return printTreeBuffer
+	var sb strings.Builder
+	tree.rootNode.PrintNode(&sb, 0)
+	return sb.String()
 }
@@ -0,0 +1,339 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopiler/internal"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// an open file and the compilation of its latest text
+type document struct {
+	text     string
+	lines    []string
+	compiler *internal.Compiler
+}
+
+type Server struct {
+	reader    *bufio.Reader
+	writer    io.Writer
+	documents map[string]*document // by URI
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		reader:    bufio.NewReader(in),
+		writer:    out,
+		documents: make(map[string]*document),
+	}
+}
+
+// Run serves requests until the client sends exit or closes the stream.
+// Returns an error if the client exits without asking to shut down first.
+func (s *Server) Run() error {
+	for {
+		request, err := readMessage(s.reader)
+		if err == io.EOF {
+			return nil // client went away
+		} else if err != nil {
+			if request == nil {
+				return err // the stream itself is broken
+			}
+			s.respondError(nil, codeParseError, err.Error())
+			continue
+		}
+
+		if request.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		s.handle(request)
+	}
+}
+
+func (s *Server) handle(request *message) {
+	switch request.Method {
+	case "initialize":
+		s.respond(request.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // full document on every change
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "gopiler-lsp"},
+		})
+	case "initialized":
+		// nothing to do
+	case "shutdown":
+		s.shutdown = true
+		s.respond(request.ID, nil)
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if s.decode(request, &params) {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if s.decode(request, &params) && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if s.decode(request, &params) {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics",
+				publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}})
+		}
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if s.decode(request, &params) {
+			s.respond(request.ID, s.hover(params))
+		}
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if s.decode(request, &params) {
+			s.respond(request.ID, s.definition(params))
+		}
+	case "textDocument/references":
+		var params referenceParams
+		if s.decode(request, &params) {
+			s.respond(request.ID, s.references(params))
+		}
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if s.decode(request, &params) {
+			s.respond(request.ID, s.documentSymbols(params.TextDocument.URI))
+		}
+
+	default:
+		if request.ID != nil {
+			s.respondError(request.ID, codeMethodNotFound, "Unsupported method: "+request.Method)
+		} // unknown notifications are ignored per the spec
+	}
+}
+
+// unmarshal params or tell the client they were bad
+func (s *Server) decode(request *message, params any) bool {
+	if err := json.Unmarshal(request.Params, params); err != nil {
+		if request.ID != nil {
+			s.respondError(request.ID, codeInvalidParams, err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+func (s *Server) respond(id *json.RawMessage, result any) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.respondError(id, codeParseError, err.Error())
+		return
+	}
+	s.send(&message{ID: id, Result: body})
+}
+
+func (s *Server) respondError(id *json.RawMessage, code int, msg string) {
+	if id == nil {
+		var null json.RawMessage = json.RawMessage("null")
+		id = &null
+	}
+	s.send(&message{ID: id, Error: &responseError{Code: code, Message: msg}})
+}
+
+func (s *Server) notify(method string, params any) {
+	body, _ := json.Marshal(params)
+	s.send(&message{Method: method, Params: body})
+}
+
+func (s *Server) send(msg *message) {
+	writeMessage(s.writer, msg) // nothing sensible to do if the client is gone
+}
+
+// recompile a document and publish what we found
+func (s *Server) update(uri string, text string) {
+	var compiler *internal.Compiler = internal.NewCompiler()
+	compiler.SetWebMode(true) // logs go to a buffer instead of stdout, which is our channel
+	compiler.SetVerbose(false)
+	if len(text) > 0 {
+		compiler.Compile(text, internal.StageCodeGen)
+	}
+
+	var doc *document = &document{text: text, lines: strings.Split(text, "\n"), compiler: compiler}
+	s.documents[uri] = doc
+
+	var diags []diagnostic = []diagnostic{}
+	for _, d := range compiler.Diagnostics() {
+		var severity int = severityError
+		if d.Severity == internal.SeverityWarning {
+			severity = severityWarning
+		}
+		var message string = d.Message
+		if d.Hint != "" {
+			message += "\nHint: " + d.Hint
+		}
+		diags = append(diags, diagnostic{
+			Range:    doc.diagnosticRange(d),
+			Severity: severity,
+			Code:     d.Code,
+			Source:   "gopiler " + strings.ToLower(string(d.Stage)),
+			Message:  message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (s *Server) hover(params textDocumentPositionParams) *hover {
+	doc, exists := s.documents[params.TextDocument.URI]
+	if !exists {
+		return nil
+	}
+	ref, found := doc.symbolAt(params.Position)
+	if !found {
+		return nil
+	}
+
+	var value string = fmt.Sprintf("```\n%s %s\n```\nDeclared in scope %s at (%d:%d)",
+		ref.Type, ref.Name, ref.Scope, ref.Declared.Line, ref.Declared.Column)
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: value},
+		Range:    doc.toRange(ref.Start, ref.End),
+	}
+}
+
+func (s *Server) definition(params textDocumentPositionParams) *location {
+	doc, exists := s.documents[params.TextDocument.URI]
+	if !exists {
+		return nil
+	}
+	ref, found := doc.symbolAt(params.Position)
+	if !found {
+		return nil
+	}
+
+	var end internal.Position = ref.Declared
+	end.Column += len(ref.Name)
+	return &location{URI: params.TextDocument.URI, Range: doc.toRange(ref.Declared, end)}
+}
+
+// every use of the symbol under the cursor, and its declaration if asked for
+func (s *Server) references(params referenceParams) []location {
+	var locations []location = []location{}
+	doc, exists := s.documents[params.TextDocument.URI]
+	if !exists {
+		return locations
+	}
+	ref, found := doc.symbolAt(params.Position)
+	if !found {
+		return locations
+	}
+
+	for _, other := range doc.compiler.ReferencesTo(ref) {
+		if !other.IsDecl || params.Context.IncludeDeclaration {
+			locations = append(locations, location{URI: params.TextDocument.URI, Range: doc.toRange(other.Start, other.End)})
+		}
+	}
+	return locations
+}
+
+// each program is a namespace holding the variables declared in it
+func (s *Server) documentSymbols(uri string) []documentSymbol {
+	var symbols []documentSymbol = []documentSymbol{}
+	doc, exists := s.documents[uri]
+	if !exists {
+		return symbols
+	}
+
+	for program := 0; program < doc.compiler.ProgramCount(); program++ {
+		start, end, ok := doc.compiler.ProgramSpan(program)
+		if !ok {
+			continue
+		}
+		var programRange lspRange = doc.toRange(start, end)
+		var programSymbol documentSymbol = documentSymbol{
+			Name:           fmt.Sprintf("Program %d", program+1),
+			Kind:           symbolKindNamespace,
+			Range:          programRange,
+			SelectionRange: programRange,
+		}
+		for _, decl := range doc.compiler.Declarations(program) {
+			var declRange lspRange = doc.toRange(decl.Start, decl.End)
+			programSymbol.Children = append(programSymbol.Children, documentSymbol{
+				Name:           decl.Name,
+				Detail:         fmt.Sprintf("%s (scope %s)", decl.Type, decl.Scope),
+				Kind:           symbolKindVariable,
+				Range:          declRange,
+				SelectionRange: declRange,
+			})
+		}
+		symbols = append(symbols, programSymbol)
+	}
+	return symbols
+}
+
+func (doc *document) symbolAt(pos position) (internal.SymbolRef, bool) {
+	var p internal.Position = doc.fromLsp(pos)
+	return doc.compiler.SymbolAt(p.Line, p.Column)
+}
+
+// compiler positions are 1 based and count runes, LSP is 0 based and counts UTF-16 units
+func (doc *document) toLsp(p internal.Position) position {
+	if p.Line <= 0 {
+		return position{} // unknown, point at the top of the file
+	}
+	var character int = 0
+	if p.Line <= len(doc.lines) {
+		var runes []rune = []rune(doc.lines[p.Line-1])
+		for i := 0; i < p.Column-1 && i < len(runes); i++ {
+			character += utf16Len(runes[i])
+		}
+	}
+	return position{Line: p.Line - 1, Character: character}
+}
+
+func (doc *document) fromLsp(pos position) internal.Position {
+	var column int = 1
+	if pos.Line < len(doc.lines) {
+		var units int = 0
+		for _, r := range doc.lines[pos.Line] {
+			if units >= pos.Character {
+				break
+			}
+			units += utf16Len(r)
+			column++
+		}
+	}
+	return internal.Position{Line: pos.Line + 1, Column: column}
+}
+
+func (doc *document) toRange(start internal.Position, end internal.Position) lspRange {
+	return lspRange{Start: doc.toLsp(start), End: doc.toLsp(end)}
+}
+
+// diagnostics without a location (like running out of memory) cover their program
+func (doc *document) diagnosticRange(d internal.Diagnostic) lspRange {
+	if d.Start.Line > 0 {
+		return doc.toRange(d.Start, d.End)
+	}
+	if start, _, ok := doc.compiler.ProgramSpan(d.Program); ok {
+		return doc.toRange(start, start)
+	}
+	return lspRange{}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 && utf8.ValidRune(r) {
+		return 2
+	}
+	return 1
+}
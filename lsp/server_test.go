@@ -0,0 +1,195 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// talks to a Server over a pair of pipes, like an editor on its stdio
+type client struct {
+	t      *testing.T
+	writer *io.PipeWriter
+	reader *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+func startServer(t *testing.T) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	var c *client = &client{t: t, writer: inWriter, reader: bufio.NewReader(outReader), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inReader, outWriter).Run()
+		outWriter.Close()
+	}()
+	return c
+}
+
+func (c *client) send(msg *message) {
+	c.t.Helper()
+	if err := writeMessage(c.writer, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	body, _ := json.Marshal(params)
+	c.send(&message{Method: method, Params: body})
+}
+
+// sends a request and unmarshals the result of its response into result
+func (c *client) request(method string, params any, result any) {
+	c.t.Helper()
+	c.nextID++
+	var id json.RawMessage = json.RawMessage(strconv.Itoa(c.nextID))
+	body, _ := json.Marshal(params)
+	c.send(&message{ID: &id, Method: method, Params: body})
+
+	var response *message = c.receive()
+	if response.ID == nil || string(*response.ID) != string(id) || response.Error != nil {
+		c.t.Fatalf("%s: got %+v, want the response to request %s", method, response, id)
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		c.t.Fatalf("%s: %v in %s", method, err, response.Result)
+	}
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+	msg, err := readMessage(c.reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// the diagnostics published after opening or changing a document
+func (c *client) diagnostics() publishDiagnosticsParams {
+	c.t.Helper()
+	var msg *message = c.receive()
+	var params publishDiagnosticsParams
+	if msg.Method != "textDocument/publishDiagnostics" || json.Unmarshal(msg.Params, &params) != nil {
+		c.t.Fatalf("got %+v, want published diagnostics", msg)
+	}
+	return params
+}
+
+func at(line int, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]string{"uri": testURI},
+		"position":     position{Line: line, Character: character},
+	}
+}
+
+const testURI string = "file:///test.gop"
+
+func TestServer(t *testing.T) {
+	var c *client = startServer(t)
+
+	var initialize struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &initialize)
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "referencesProvider", "documentSymbolProvider"} {
+		if initialize.Capabilities[capability] != true {
+			t.Errorf("initialize did not advertise %s: %v", capability, initialize.Capabilities)
+		}
+	}
+	c.notify("initialized", map[string]any{})
+
+	// c is undeclared on the last line
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{
+		"uri": testURI, "languageId": "gopiler", "version": 1,
+		"text": "{int a a = 1\nprint(a)}$\n{int b b = c}$",
+	}})
+	var published publishDiagnosticsParams = c.diagnostics()
+	var undeclared bool
+	for _, d := range published.Diagnostics {
+		if d.Code == "SEM-UNDECLARED" && d.Severity == severityError && d.Range.Start == (position{Line: 2, Character: 11}) {
+			undeclared = true
+		}
+	}
+	if published.URI != testURI || !undeclared {
+		t.Errorf("didOpen published %+v, want the undeclared c at 2:11", published)
+	}
+
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": testURI, "version": 2},
+		"contentChanges": []map[string]string{{"text": "{int a a = 1\nprint(a)\na = 2 + a}$"}},
+	})
+	for _, d := range c.diagnostics().Diagnostics {
+		if d.Severity == severityError {
+			t.Errorf("didChange published %+v for a program with no errors", d)
+		}
+	}
+
+	// the a in print(a)
+	var hovered hover
+	c.request("textDocument/hover", at(1, 6), &hovered)
+	if !strings.Contains(hovered.Contents.Value, "int a") || hovered.Range.Start != (position{Line: 1, Character: 6}) {
+		t.Errorf("hover gave %+v", hovered)
+	}
+
+	var declared location
+	c.request("textDocument/definition", at(1, 6), &declared)
+	if declared.URI != testURI || declared.Range != (lspRange{Start: position{0, 5}, End: position{0, 6}}) {
+		t.Errorf("definition gave %+v, want 0:5", declared)
+	}
+
+	var uses []location
+	c.request("textDocument/references", map[string]any{
+		"textDocument": map[string]string{"uri": testURI},
+		"position":     position{Line: 2, Character: 8},
+		"context":      map[string]bool{"includeDeclaration": true},
+	}, &uses)
+	var want []position = []position{{0, 5}, {0, 7}, {1, 6}, {2, 0}, {2, 8}}
+	if len(uses) != len(want) {
+		t.Fatalf("references gave %+v, want %v", uses, want)
+	}
+	for i, use := range uses {
+		if use.Range.Start != want[i] {
+			t.Errorf("reference %d is at %v, want %v", i, use.Range.Start, want[i])
+		}
+	}
+	c.request("textDocument/references", map[string]any{
+		"textDocument": map[string]string{"uri": testURI},
+		"position":     position{Line: 0, Character: 5},
+		"context":      map[string]bool{"includeDeclaration": false},
+	}, &uses)
+	if len(uses) != len(want)-1 || uses[0].Range.Start != want[1] {
+		t.Errorf("references without the declaration gave %+v", uses)
+	}
+
+	var symbols []documentSymbol
+	c.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]string{"uri": testURI}}, &symbols)
+	if len(symbols) != 1 || len(symbols[0].Children) != 1 || symbols[0].Children[0].Name != "a" {
+		t.Errorf("documentSymbol gave %+v, want program 1 holding a", symbols)
+	}
+
+	// nothing is under the cursor on a digit
+	var nothing *hover
+	c.request("textDocument/hover", at(0, 11), &nothing)
+	if nothing != nil {
+		t.Errorf("hover on a digit gave %+v", nothing)
+	}
+
+	var shutdown any
+	c.request("shutdown", nil, &shutdown)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Run returned %v after shutdown and exit", err)
+	}
+}
+
+func TestExitBeforeShutdown(t *testing.T) {
+	var c *client = startServer(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err == nil {
+		t.Error("Run returned no error for an exit without shutdown")
+	}
+}
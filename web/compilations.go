@@ -0,0 +1,68 @@
+package web
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"gopiler/internal"
+	"sync"
+)
+
+// how many finished compilations we hold on to before evicting the oldest
+const maxCachedCompilations int = 64
+
+// everything one /compile request produced
+type compilation struct {
+	id       string
+	output   string // rendered log
+	compiler *internal.Compiler
+}
+
+// bounded least-recently-used store of compilations, safe for concurrent requests
+type compilationCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List               // front is most recently used
+	entries  map[string]*list.Element // id to element holding *compilation
+}
+
+func newCompilationCache(capacity int) *compilationCache {
+	return &compilationCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func newCompilationID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating a compilation id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func (cache *compilationCache) add(comp *compilation) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries[comp.id] = cache.order.PushFront(comp)
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*compilation).id)
+	}
+}
+
+func (cache *compilationCache) get(id string) (*compilation, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	elem, exists := cache.entries[id]
+	if !exists {
+		return nil, false
+	}
+	cache.order.MoveToFront(elem)
+	return elem.Value.(*compilation), true
+}
@@ -16,9 +16,39 @@ type CompileRequest struct {
 }
 
 type CompileResponse struct {
+	ID     string `json:"id"`
 	Output string `json:"output"`
 }
 
+// find the compilation named in the URL or respond 404
+func lookupCompilation(compilations *compilationCache, c *gin.Context) (*compilation, bool) {
+	comp, exists := compilations.get(c.Param("id"))
+	if !exists {
+		c.String(http.StatusNotFound, "Unknown or expired compilation")
+	}
+	return comp, exists
+}
+
+// program number from the URL or respond 400
+func programParam(c *gin.Context) (int, bool) {
+	program, err := strconv.Atoi(c.Param("program"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid program number")
+		return 0, false
+	}
+	return program, true
+}
+
+// tree format from the query or respond 400
+func graphFormatParam(c *gin.Context) (internal.GraphFormat, bool) {
+	format, err := internal.ParseGraphFormat(c.DefaultQuery("format", string(internal.GraphText)))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return format, true
+}
+
 func StartServer(expose bool) {
 	r := gin.Default()
 	r.SetTrustedProxies(nil) // Disable trusting any proxies
@@ -34,6 +64,19 @@ func StartServer(expose bool) {
 		c.HTML(http.StatusOK, "index.html", nil)
 	})
 
+	addCompileRoutes(r, newCompilationCache(maxCachedCompilations))
+
+	if expose {
+		log.Println("Web server exposed to internet; [host_ip]:8080")
+		r.Run("0.0.0.0:8080") // exposed to internet
+	} else {
+		log.Println("Web server running on localhost; 0.0.0.0:8080")
+		r.Run(":8080") // localhost
+	}
+}
+
+// /compile and the /compilations/:id/... artifacts, kept in compilations
+func addCompileRoutes(r *gin.Engine, compilations *compilationCache) {
 	// Handle compilation requests
 	r.POST("/compile", func(c *gin.Context) {
 		var request struct {
@@ -45,77 +88,123 @@ func StartServer(expose bool) {
 			return
 		}
 
-		output := runCompiler(request.Code, request.Verbose)
-		c.JSON(http.StatusOK, gin.H{"output": output})
+		comp, err := runCompiler(request.Code, request.Verbose)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		compilations.add(comp)
+		c.JSON(http.StatusOK, CompileResponse{ID: comp.id, Output: comp.output})
+	})
+
+	// artifacts of a single compilation
+	// the trees take ?format=text (default), dot, or mermaid
+	r.GET("/compilations/:id/cst", func(c *gin.Context) {
+		if comp, ok := lookupCompilation(compilations, c); ok {
+			if format, ok := graphFormatParam(c); ok {
+				c.String(http.StatusOK, comp.compiler.GetCstAs(format))
+			}
+		}
+	})
+
+	r.GET("/compilations/:id/ast", func(c *gin.Context) {
+		if comp, ok := lookupCompilation(compilations, c); ok {
+			if format, ok := graphFormatParam(c); ok {
+				c.String(http.StatusOK, comp.compiler.GetAstAs(format))
+			}
+		}
 	})
 
-	r.GET("/getCST", func(c *gin.Context) {
-		cst := internal.GetCst()
-		c.String(http.StatusOK, cst)
+	r.GET("/compilations/:id/diagnostics", func(c *gin.Context) {
+		if comp, ok := lookupCompilation(compilations, c); ok {
+			c.JSON(http.StatusOK, comp.compiler.Diagnostics())
+		}
 	})
 
-	r.GET("/getAST", func(c *gin.Context) {
-		ast := internal.GetAst()
-		c.String(http.StatusOK, ast)
+	// every artifact of every program, for scripts
+	r.GET("/compilations/:id/json", func(c *gin.Context) {
+		if comp, ok := lookupCompilation(compilations, c); ok {
+			c.JSON(http.StatusOK, comp.compiler.Dump())
+		}
 	})
 
 	// for symbol table display box
-	r.GET("/getSymbolTables", func(c *gin.Context) {
-		symbolTables := internal.GetSymbolTables()
-		c.JSON(http.StatusOK, symbolTables)
+	r.GET("/compilations/:id/symbols", func(c *gin.Context) {
+		if comp, ok := lookupCompilation(compilations, c); ok {
+			c.JSON(http.StatusOK, comp.compiler.GetSymbolTables())
+		}
 	})
 
 	// for machine code display box
-	r.GET("/getMachineCode/:program", func(c *gin.Context) {
-		programStr := c.Param("program")
-		program, err := strconv.Atoi(programStr)
-		if err != nil {
-			c.String(http.StatusBadRequest, "Invalid program number")
+	r.GET("/compilations/:id/machine-code/:program", func(c *gin.Context) {
+		comp, ok := lookupCompilation(compilations, c)
+		if !ok {
 			return
 		}
-
-		machineCode := internal.GetMachineCode(program, false)
-		c.String(http.StatusOK, machineCode)
+		if program, ok := programParam(c); ok {
+			c.String(http.StatusOK, comp.compiler.GetMachineCode(program, false))
+		}
 	})
 
-	// for machine code display box
-	r.GET("/getAssembly/:program", func(c *gin.Context) {
-		programStr := c.Param("program")
-		program, err := strconv.Atoi(programStr)
+	// the machine code as a file to download, ?format= like the CLI's -format (raw by default)
+	r.GET("/compilations/:id/image/:program", func(c *gin.Context) {
+		comp, ok := lookupCompilation(compilations, c)
+		if !ok {
+			return
+		}
+		program, ok := programParam(c)
+		if !ok {
+			return
+		}
+		format, err := internal.ParseImageFormat(c.DefaultQuery("format", string(internal.FormatRaw)))
 		if err != nil {
-			c.String(http.StatusBadRequest, "Invalid program number")
+			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
-
-		machineCode := internal.GetAssembly(program)
-		c.String(http.StatusOK, machineCode)
+		var memory *[256]byte = comp.compiler.GetMemoryImage(program)
+		if memory == nil {
+			c.String(http.StatusNotFound, "Program did not compile")
+			return
+		}
+		var name string = fmt.Sprintf("program%d%s", program+1, format.Extension())
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		c.Data(http.StatusOK, "application/octet-stream", internal.EncodeImage(internal.Image{Program: program, Memory: *memory}, format))
 	})
 
-	if expose {
-		log.Println("Web server exposed to internet; [host_ip]:8080")
-		r.Run("0.0.0.0:8080") // exposed to internet
-	} else {
-		log.Println("Web server running on localhost; 0.0.0.0:8080")
-		r.Run(":8080") // localhost
-	}
+	r.GET("/compilations/:id/assembly/:program", func(c *gin.Context) {
+		comp, ok := lookupCompilation(compilations, c)
+		if !ok {
+			return
+		}
+		if program, ok := programParam(c); ok {
+			c.String(http.StatusOK, comp.compiler.GetAssembly(program))
+		}
+	})
 }
 
-func runCompiler(code string, verbose bool) string {
-	internal.ResetAll()
-	var output string
+func runCompiler(code string, verbose bool) (*compilation, error) {
+	id, err := newCompilationID()
+	if err != nil {
+		return nil, err
+	}
+	var compiler *internal.Compiler = internal.NewCompiler()
 
-	internal.SetVerbose(verbose)
+	compiler.SetWebMode(true)
+	compiler.SetVerbose(verbose)
 
-	internal.Info(fmt.Sprintf("Starting compilation with verbose mode: %t", internal.Verbose), "GOPILER", true)
+	compiler.Info(fmt.Sprintf("Starting compilation with verbose mode: %t", compiler.Verbose), "GOPILER", true)
 
 	if len(code) == 0 {
-		internal.Warn("No code provided. No compilation will be executed.", "GOPILER")
+		compiler.Warn("No code provided. No compilation will be executed.", "GOPILER")
 	} else {
-		internal.Lex(code)
+		compiler.Compile(code, internal.StageCodeGen)
 	}
 
-	internal.Info("All compilations complete.", "GOPILER", true)
-	output = internal.GetLogOutput() // retrieve logs
+	compiler.Info("All compilations complete.", "GOPILER", true)
 
-	return output
+	return &compilation{
+		id:       id,
+		output:   compiler.GetLogOutput(), // retrieve logs
+		compiler: compiler,
+	}, nil
 }
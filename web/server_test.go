@@ -0,0 +1,115 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testRouter(capacity int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	var r *gin.Engine = gin.New()
+	addCompileRoutes(r, newCompilationCache(capacity))
+	return r
+}
+
+func request(r *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	var recorder *httptest.ResponseRecorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func compile(t *testing.T, r *gin.Engine, code string) string {
+	body, _ := json.Marshal(map[string]any{"code": code})
+	var recorder *httptest.ResponseRecorder = request(r, http.MethodPost, "/compile", string(body))
+	var response CompileResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); recorder.Code != http.StatusOK || err != nil || response.ID == "" {
+		t.Fatalf("/compile gave %d %s", recorder.Code, recorder.Body.String())
+	}
+	return response.ID
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	var cache *compilationCache = newCompilationCache(2)
+	cache.add(&compilation{id: "a"})
+	cache.add(&compilation{id: "b"})
+	cache.get("a") // b is now the oldest
+	cache.add(&compilation{id: "c"})
+
+	for id, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, exists := cache.get(id); exists != want {
+			t.Errorf("%s cached: %v, want %v", id, exists, want)
+		}
+	}
+}
+
+func TestCompilationIDsAreUnique(t *testing.T) {
+	var seen map[string]bool = make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := newCompilationID()
+		if err != nil || seen[id] || len(id) != 16 {
+			t.Fatalf("id %q (%v) is not a new 16 digit id", id, err)
+		}
+		seen[id] = true
+	}
+}
+
+// each id serves its own compilation's artifacts
+func TestCompilationsAreIsolated(t *testing.T) {
+	var r *gin.Engine = testRouter(8)
+	var first string = compile(t, r, `{print("first")}$`)
+	var second string = compile(t, r, `{int a a = 7 print(a)}$ {print(1)}$`)
+
+	for _, test := range []struct {
+		path    string
+		want    string
+		notWant string
+	}{
+		{"/compilations/" + first + "/ast", `{STRING [ first ]}`, `{DIGIT [ 7 ]}`},
+		{"/compilations/" + second + "/ast", `{DIGIT [ 7 ]}`, `{STRING [ first ]}`},
+		{"/compilations/" + first + "/machine-code/0", "A0", ""},
+		{"/compilations/" + second + "/assembly/1", "LDY #$01", ""},
+	} {
+		var recorder *httptest.ResponseRecorder = request(r, http.MethodGet, test.path, "")
+		var body string = recorder.Body.String()
+		if recorder.Code != http.StatusOK || !strings.Contains(body, test.want) ||
+			(test.notWant != "" && strings.Contains(body, test.notWant)) {
+			t.Errorf("%s gave %d:\n%s", test.path, recorder.Code, body)
+		}
+	}
+
+	var dumps []map[string]any
+	json.Unmarshal(request(r, http.MethodGet, "/compilations/"+first+"/json", "").Body.Bytes(), &dumps)
+	if len(dumps) != 1 {
+		t.Errorf("first compilation has %d programs, want 1", len(dumps))
+	}
+	json.Unmarshal(request(r, http.MethodGet, "/compilations/"+second+"/json", "").Body.Bytes(), &dumps)
+	if len(dumps) != 2 {
+		t.Errorf("second compilation has %d programs, want 2", len(dumps))
+	}
+
+	if code := request(r, http.MethodGet, "/compilations/nope/ast", "").Code; code != http.StatusNotFound {
+		t.Errorf("an unknown id gave %d, want 404", code)
+	}
+	if code := request(r, http.MethodGet, "/compilations/"+first+"/assembly/x", "").Code; code != http.StatusBadRequest {
+		t.Errorf("a bad program number gave %d, want 400", code)
+	}
+}
+
+func TestOldCompilationsExpire(t *testing.T) {
+	var r *gin.Engine = testRouter(2)
+	var first string = compile(t, r, `{print(1)}$`)
+	var second string = compile(t, r, `{print(2)}$`)
+	request(r, http.MethodGet, "/compilations/"+first+"/ast", "") // first is now the most recent
+	var third string = compile(t, r, `{print(3)}$`)
+
+	for id, want := range map[string]int{first: http.StatusOK, second: http.StatusNotFound, third: http.StatusOK} {
+		if code := request(r, http.MethodGet, "/compilations/"+id+"/diagnostics", "").Code; code != want {
+			t.Errorf("compilation %s gave %d, want %d", id, code, want)
+		}
+	}
+}
@@ -1,3 +1,6 @@
+// id of the compilation whose artifacts are on screen
+let compilationId = null;
+
 document.addEventListener("DOMContentLoaded", function () {
     let verboseFlag = true;
 
@@ -83,13 +86,14 @@ document.addEventListener("DOMContentLoaded", function () {
             .then(data => {
                 if (data.output) {
                     document.getElementById("consoleOutput").innerHTML = data.output;
+                    compilationId = data.id;
 
                     // After successfully receiving the compilation output, send GET requests 
                     return Promise.all([
-                        fetch("/getSymbolTables").then(response => response.json()),
-                        fetch("/getMachineCode/0").then(response => response.text()),
-                        fetch("/getCST").then(response => response.text()),
-                        fetch("/getAST").then(response => response.text())
+                        fetch(`/compilations/${compilationId}/symbols`).then(response => response.json()),
+                        fetch(`/compilations/${compilationId}/machine-code/0`).then(response => response.text()),
+                        fetch(`/compilations/${compilationId}/cst`).then(response => response.text()),
+                        fetch(`/compilations/${compilationId}/ast`).then(response => response.text())
                     ]);
                 } else {
                     throw new Error("Compilation failed");
@@ -189,11 +193,15 @@ function updateMachineCodeBox() {
     const viewMode = document.getElementById('machineViewType').value;  // "Machine Code" or "Assembly"
     const programNumber = document.getElementById('programCounter').value - 1; // backend index from 0 
 
+    if (compilationId === null) {
+        return; // nothing compiled yet
+    }
+
     let endpoint = '';
     if (viewMode === 'Assembly') {
-        endpoint = `/getAssembly/${programNumber}`;
+        endpoint = `/compilations/${compilationId}/assembly/${programNumber}`;
     } else {
-        endpoint = `/getMachineCode/${programNumber}`;
+        endpoint = `/compilations/${compilationId}/machine-code/${programNumber}`;
     }
 
     fetch(endpoint)